@@ -0,0 +1,27 @@
+package safemap
+
+// SetAsync sets the value for the given key in the SafeMap without waiting for the worker to apply it.
+// It returns as soon as the operation is accepted, which is immediate while the queue configured with WithQueueSize has room.
+// Operations sent from the same goroutine are applied in order, so a later Get from that goroutine observes the value,
+// unless it is served outside the worker because of WithReadConcurrency or WithSnapshotReads.
+// The value is dropped if the SafeMap is closed.
+// example
+//
+//	m := NewSafeMap(WithQueueSize[string, int](1024))
+//	m.SetAsync("last-seen", 42)
+func (s *SafeMap[k, v]) SetAsync(key k, val v) {
+	s.sendAsync(operation[k, v]{
+		op:    "set",
+		key:   key,
+		value: val,
+	})
+}
+
+// DeleteAsync removes the key-value pair for the given key from the SafeMap without waiting for the worker to apply it.
+// It follows the same rules as SetAsync.
+func (s *SafeMap[k, v]) DeleteAsync(key k) {
+	s.sendAsync(operation[k, v]{
+		op:  "delete",
+		key: key,
+	})
+}
@@ -0,0 +1,44 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_SetAsync(t *testing.T) {
+	m := NewSafeMap(WithQueueSize[int, int](16))
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w * 100; i < (w+1)*100; i++ {
+				m.SetAsync(i, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the synchronous read is queued after every asynchronous write
+	assert.Equal(t, 400, m.Length())
+
+	m.DeleteAsync(1)
+	assert.False(t, m.Exist(1))
+
+	mu := NewSafeMap(WithMutex[int, int]())
+	mu.SetAsync(1, 1)
+	assert.Equal(t, 1, mu.Get(1))
+	mu.DeleteAsync(1)
+	assert.False(t, mu.Exist(1))
+
+	var zero SafeMap[int, int]
+	zero.SetAsync(1, 1)
+	assert.Equal(t, 1, zero.Get(1))
+
+	assert.NoError(t, m.Close())
+	m.SetAsync(1, 1)
+	assert.False(t, m.Exist(1))
+}
@@ -0,0 +1,58 @@
+package safemap
+
+import "iter"
+
+type (
+	// Backend is the storage behind a SafeMap.
+	// The SafeMap serializes every call, so implementations don't need to be safe for concurrent use,
+	// but a Backend must not be shared between several SafeMaps.
+	// Read methods (Get, Len and All) may be called in parallel when WithReadConcurrency or WithMutex is used,
+	// so they must not modify the Backend.
+	Backend[k comparable, v any] interface {
+		// Get returns the value stored for key and whether it is present.
+		Get(key k) (v, bool)
+		// Set stores the value for key.
+		Set(key k, val v)
+		// Delete removes key, it is a no-op if the key is not present.
+		Delete(key k)
+		// Len returns the number of stored entries.
+		Len() int
+		// All returns an iterator over the stored entries. The SafeMap never modifies the Backend while iterating.
+		All() iter.Seq2[k, v]
+	}
+
+	// mapBackend is the default Backend, a plain Go map.
+	mapBackend[k comparable, v any] map[k]v
+)
+
+// Get returns the value stored for key and whether it is present.
+func (m mapBackend[k, v]) Get(key k) (v, bool) {
+	val, ok := m[key]
+	return val, ok
+}
+
+// Set stores the value for key.
+func (m mapBackend[k, v]) Set(key k, val v) {
+	m[key] = val
+}
+
+// Delete removes key.
+func (m mapBackend[k, v]) Delete(key k) {
+	delete(m, key)
+}
+
+// Len returns the number of stored entries.
+func (m mapBackend[k, v]) Len() int {
+	return len(m)
+}
+
+// All returns an iterator over the stored entries.
+func (m mapBackend[k, v]) All() iter.Seq2[k, v] {
+	return func(yield func(k, v) bool) {
+		for key, val := range m {
+			if !yield(key, val) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package safemap
+
+import (
+	"iter"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// boundedBackend is a Backend keeping at most limit entries, ignoring new keys once full.
+type boundedBackend struct {
+	limit int
+	data  map[string]int
+}
+
+func (b *boundedBackend) Get(key string) (int, bool) {
+	val, ok := b.data[key]
+	return val, ok
+}
+
+func (b *boundedBackend) Set(key string, val int) {
+	if _, ok := b.data[key]; !ok && len(b.data) >= b.limit {
+		return
+	}
+	b.data[key] = val
+}
+
+func (b *boundedBackend) Delete(key string) {
+	delete(b.data, key)
+}
+
+func (b *boundedBackend) Len() int {
+	return len(b.data)
+}
+
+func (b *boundedBackend) All() iter.Seq2[string, int] {
+	return maps.All(b.data)
+}
+
+func TestWithBackend(t *testing.T) {
+	store := &boundedBackend{limit: 3, data: make(map[string]int)}
+	m := NewSafeMap(WithBackend[string, int](store))
+
+	m.SetMany(map[string]int{"a": 1, "b": 2, "c": 3})
+	m.Set("d", 4)
+
+	assert.Equal(t, 3, m.Length())
+	assert.False(t, m.Exist("d"))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, m.GetMap())
+
+	m.Update("a", func(old int, exists bool) (int, bool) {
+		return old + 10, true
+	})
+	assert.Equal(t, 11, store.data["a"])
+
+	assert.Equal(t, 1, m.DeleteFunc(func(key string, val int) bool { return val == 2 }))
+	m.TransformValues(func(key string, val int) int { return val * 2 })
+	assert.Equal(t, map[string]int{"a": 22, "c": 6}, store.data)
+
+	clone := m.Clone()
+	clone.Set("x", 1)
+	clone.Set("y", 1)
+	assert.Equal(t, 4, clone.Length())
+
+	assert.Equal(t, 2, m.Clear())
+	assert.Empty(t, store.data)
+}
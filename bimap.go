@@ -0,0 +1,189 @@
+package safemap
+
+import (
+	"iter"
+	"slices"
+	"sync"
+)
+
+// SafeBiMap is a thread-safe one-to-one map, looking up keys by value as efficiently as values by key.
+// Both directions are updated in a single operation of the worker goroutine, so they never disagree.
+// A value is held by a single key: setting a value held by another key removes that other key.
+// The zero value is an empty SafeBiMap ready to use, NewSafeBiMap is only needed to pass options.
+// A SafeBiMap must not be copied after first use.
+// example
+//
+//	users := NewSafeBiMap[int, string]()
+//	users.Set(1, "alice")
+//	id, _ := users.GetByValue("alice") // 1
+type SafeBiMap[k comparable, v comparable] struct {
+	m SafeMap[k, v]
+
+	// once lazily creates the SafeMap of a zero value SafeBiMap over a biBackend.
+	once sync.Once
+}
+
+type (
+	// inverseStore is implemented by biBackend, it lets the worker look keys up by value without the comparable constraint on v.
+	inverseStore[k comparable, v any] interface {
+		keyOf(val v) (k, bool)
+	}
+
+	// biBackend is the Backend of a SafeBiMap, a map along with its inverse.
+	// Its inverse only tracks the last key a value was set for, the SafeBiMap removes the other ones.
+	biBackend[k comparable, v comparable] struct {
+		forward map[k]v
+		inverse map[v]k
+	}
+)
+
+// NewSafeBiMap creates a SafeBiMap with the given options.
+// It panics if combined with WithBackend, WithCopyOnWrite, WithSnapshotReads or WithInsertionOrder.
+// example
+//
+//	sessions := NewSafeBiMap(WithDefaultTTL[string, string](time.Hour))
+func NewSafeBiMap[k comparable, v comparable](opts ...Option[k, v]) *SafeBiMap[k, v] {
+	m := &SafeBiMap[k, v]{}
+	m.configure(opts)
+	return m
+}
+
+// configure creates the SafeMap of the SafeBiMap over a biBackend, with the given options.
+func (m *SafeBiMap[k, v]) configure(opts []Option[k, v]) {
+	m.m.configure(append(slices.Clip(opts), func(c *config[k, v]) {
+		c.newStore = func(capacity int) Backend[k, v] {
+			return &biBackend[k, v]{
+				forward: make(map[k]v, max(capacity, 0)),
+				inverse: make(map[v]k, max(capacity, 0)),
+			}
+		}
+		c.kind = "SafeBiMap"
+	}))
+}
+
+// load returns the SafeMap of the SafeBiMap, creating it with the default configuration
+// on first use of a zero value SafeBiMap.
+func (m *SafeBiMap[k, v]) load() *SafeMap[k, v] {
+	m.once.Do(func() {
+		if m.m.core == nil {
+			m.configure(nil)
+		}
+	})
+	return &m.m
+}
+
+// Set sets the value for the given key. If another key held the value, it is removed in the same operation.
+func (m *SafeBiMap[k, v]) Set(key k, val v) {
+	m.load().send(operation[k, v]{
+		op:    "setUnique",
+		key:   key,
+		value: val,
+	})
+}
+
+// Get returns the value for the given key, or the zero value if the key is not present.
+func (m *SafeBiMap[k, v]) Get(key k) v {
+	return m.load().Get(key)
+}
+
+// Lookup returns the value for the given key and whether the key is present.
+func (m *SafeBiMap[k, v]) Lookup(key k) (val v, ok bool) {
+	return m.load().Lookup(key)
+}
+
+// GetByValue returns the key holding the given value and whether the value is present.
+func (m *SafeBiMap[k, v]) GetByValue(val v) (key k, ok bool) {
+	reply := m.load().send(operation[k, v]{
+		op:    "keyOf",
+		value: val,
+	})
+	return reply.key, reply.ok
+}
+
+// Exist reports whether the given key is present.
+func (m *SafeBiMap[k, v]) Exist(key k) bool {
+	return m.load().Exist(key)
+}
+
+// ExistValue reports whether the given value is present.
+func (m *SafeBiMap[k, v]) ExistValue(val v) bool {
+	_, ok := m.GetByValue(val)
+	return ok
+}
+
+// Delete removes the given key along with its value.
+func (m *SafeBiMap[k, v]) Delete(key k) {
+	m.load().Delete(key)
+}
+
+// DeleteByValue removes the given value along with its key, which it returns.
+// The ok result reports whether the value was present.
+func (m *SafeBiMap[k, v]) DeleteByValue(val v) (key k, ok bool) {
+	reply := m.load().send(operation[k, v]{
+		op:    "deleteByValue",
+		value: val,
+	})
+	return reply.key, reply.ok
+}
+
+// Length returns the number of pairs in the SafeBiMap.
+func (m *SafeBiMap[k, v]) Length() int {
+	return m.load().Length()
+}
+
+// All returns an iterator over the pairs of the SafeBiMap at the time All is called.
+func (m *SafeBiMap[k, v]) All() iter.Seq2[k, v] {
+	return m.load().All()
+}
+
+// Clear removes all pairs and returns the number of pairs removed.
+func (m *SafeBiMap[k, v]) Clear() int {
+	return m.load().Clear()
+}
+
+// Close stops the worker goroutine of the SafeBiMap, see SafeMap.Close.
+func (m *SafeBiMap[k, v]) Close() error {
+	return m.load().Close()
+}
+
+func (b *biBackend[k, v]) Get(key k) (v, bool) {
+	val, ok := b.forward[key]
+	return val, ok
+}
+
+func (b *biBackend[k, v]) Set(key k, val v) {
+	if old, ok := b.forward[key]; ok && b.inverse[old] == key {
+		delete(b.inverse, old)
+	}
+	b.forward[key] = val
+	b.inverse[val] = key
+}
+
+func (b *biBackend[k, v]) Delete(key k) {
+	if val, ok := b.forward[key]; ok {
+		delete(b.forward, key)
+		if b.inverse[val] == key {
+			delete(b.inverse, val)
+		}
+	}
+}
+
+func (b *biBackend[k, v]) Len() int {
+	return len(b.forward)
+}
+
+func (b *biBackend[k, v]) All() iter.Seq2[k, v] {
+	return func(yield func(k, v) bool) {
+		for key, val := range b.forward {
+			if !yield(key, val) {
+				return
+			}
+		}
+	}
+}
+
+// keyOf returns the key holding val.
+func (b *biBackend[k, v]) keyOf(val v) (k, bool) {
+	key, ok := b.inverse[val]
+	return key, ok
+}
@@ -0,0 +1,90 @@
+package safemap
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeBiMap(t *testing.T) {
+	m := NewSafeBiMap[int, string]()
+	defer m.Close()
+
+	m.Set(1, "a")
+	m.Set(2, "b")
+	key, ok := m.GetByValue("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+
+	// overwriting the value of a key forgets the previous value
+	m.Set(1, "c")
+	assert.False(t, m.ExistValue("a"))
+	key, _ = m.GetByValue("c")
+	assert.Equal(t, 1, key)
+
+	// setting a value held by another key removes that key
+	m.Set(3, "b")
+	assert.False(t, m.Exist(2))
+	key, _ = m.GetByValue("b")
+	assert.Equal(t, 3, key)
+	assert.Equal(t, 2, m.Length())
+
+	key, ok = m.DeleteByValue("c")
+	assert.True(t, ok)
+	assert.Equal(t, 1, key)
+	assert.False(t, m.Exist(1))
+	_, ok = m.DeleteByValue("c")
+	assert.False(t, ok)
+
+	m.Delete(3)
+	assert.False(t, m.ExistValue("b"))
+	assert.Zero(t, m.Length())
+}
+
+func TestSafeBiMap_Consistent(t *testing.T) {
+	m := NewSafeBiMap[int, int]()
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				m.Set((w+i)%20, i%10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, m.Length(), 10, "each value is held by a single key")
+	for key, val := range m.All() {
+		other, ok := m.GetByValue(val)
+		assert.True(t, ok)
+		assert.Equal(t, key, other, fmt.Sprint(val))
+	}
+}
+
+func TestSafeBiMap_TTL(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeBiMap(WithDefaultTTL[string, string](time.Minute), withClock[string, string](clock.Now))
+	defer m.Close()
+
+	m.Set("session", "alice")
+	clock.Advance(2 * time.Minute)
+	assert.False(t, m.ExistValue("alice"), "an expired pair is removed in both directions")
+}
+
+func TestSafeBiMap_ZeroValue(t *testing.T) {
+	var users SafeBiMap[int, string]
+	users.Set(1, "alice")
+	users.Set(2, "alice")
+	id, ok := users.GetByValue("alice")
+	assert.True(t, ok)
+	assert.Equal(t, 2, id)
+	assert.Equal(t, 1, users.Length())
+	assert.NoError(t, users.Close())
+}
@@ -0,0 +1,283 @@
+package safemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// binaryVersion is the first byte of the binary encoding of a SafeMap, identifying its format.
+const binaryVersion = 1
+
+// errCorrupt is returned when decoding data that is not a binary encoding of a SafeMap.
+var errCorrupt = errors.New("safemap: corrupt binary encoding")
+
+// Codec encodes the keys or the values of a SafeMap for MarshalBinary and UnmarshalBinary, see WithCodec.
+type Codec[T any] interface {
+	// Append appends the encoding of val to buf and returns the extended buffer.
+	Append(buf []byte, val T) ([]byte, error)
+
+	// Decode decodes a value from the bytes produced by Append, data must not be retained.
+	Decode(data []byte) (T, error)
+}
+
+// defaultCodec encodes strings, byte slices, booleans, integers and floats, and types implementing
+// encoding.BinaryMarshaler, with encoding.BinaryUnmarshaler implemented by their pointer.
+type defaultCodec[T any] struct{}
+
+func (defaultCodec[T]) Append(buf []byte, val T) ([]byte, error) {
+	switch val := any(val).(type) {
+	case string:
+		return append(buf, val...), nil
+	case []byte:
+		return append(buf, val...), nil
+	case bool:
+		if val {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case int:
+		return binary.AppendVarint(buf, int64(val)), nil
+	case int8:
+		return binary.AppendVarint(buf, int64(val)), nil
+	case int16:
+		return binary.AppendVarint(buf, int64(val)), nil
+	case int32:
+		return binary.AppendVarint(buf, int64(val)), nil
+	case int64:
+		return binary.AppendVarint(buf, val), nil
+	case uint:
+		return binary.AppendUvarint(buf, uint64(val)), nil
+	case uint8:
+		return binary.AppendUvarint(buf, uint64(val)), nil
+	case uint16:
+		return binary.AppendUvarint(buf, uint64(val)), nil
+	case uint32:
+		return binary.AppendUvarint(buf, uint64(val)), nil
+	case uint64:
+		return binary.AppendUvarint(buf, val), nil
+	case float32:
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(val)), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(val)), nil
+	case encoding.BinaryMarshaler:
+		data, err := val.MarshalBinary()
+		return append(buf, data...), err
+	}
+	return buf, fmt.Errorf("safemap: no binary codec for %T, see WithCodec", val)
+}
+
+func (defaultCodec[T]) Decode(data []byte) (val T, err error) {
+	var decoded any
+	switch any(val).(type) {
+	case string:
+		decoded = string(data)
+	case []byte:
+		decoded = append([]byte(nil), data...)
+	case bool:
+		if len(data) != 1 {
+			return val, errCorrupt
+		}
+		decoded = data[0] == 1
+	case int, int8, int16, int32, int64:
+		n, size := binary.Varint(data)
+		if size <= 0 || size != len(data) {
+			return val, errCorrupt
+		}
+		decoded = convertInt[T](n)
+	case uint, uint8, uint16, uint32, uint64:
+		n, size := binary.Uvarint(data)
+		if size <= 0 || size != len(data) {
+			return val, errCorrupt
+		}
+		decoded = convertUint[T](n)
+	case float32:
+		if len(data) != 4 {
+			return val, errCorrupt
+		}
+		decoded = math.Float32frombits(binary.LittleEndian.Uint32(data))
+	case float64:
+		if len(data) != 8 {
+			return val, errCorrupt
+		}
+		decoded = math.Float64frombits(binary.LittleEndian.Uint64(data))
+	default:
+		u, ok := any(&val).(encoding.BinaryUnmarshaler)
+		if !ok {
+			return val, fmt.Errorf("safemap: no binary codec for %T, see WithCodec", val)
+		}
+		return val, u.UnmarshalBinary(data)
+	}
+	return decoded.(T), nil
+}
+
+// convertInt converts n to the signed integer type T.
+func convertInt[T any](n int64) any {
+	var val T
+	switch any(val).(type) {
+	case int:
+		return int(n)
+	case int8:
+		return int8(n)
+	case int16:
+		return int16(n)
+	case int32:
+		return int32(n)
+	}
+	return n
+}
+
+// convertUint converts n to the unsigned integer type T.
+func convertUint[T any](n uint64) any {
+	var val T
+	switch any(val).(type) {
+	case uint:
+		return uint(n)
+	case uint8:
+		return uint8(n)
+	case uint16:
+		return uint16(n)
+	case uint32:
+		return uint32(n)
+	}
+	return n
+}
+
+// codecs returns the codecs of the keys and the values, the default ones unless set with WithCodec.
+func (c *core[k, v]) codecs() (Codec[k], Codec[v]) {
+	keys, values := c.cfg.keyCodec, c.cfg.valueCodec
+	if keys == nil {
+		keys = defaultCodec[k]{}
+	}
+	if values == nil {
+		values = defaultCodec[v]{}
+	}
+	return keys, values
+}
+
+// MarshalBinary encodes the entries of the SafeMap in a compact format: a version byte and the number of entries,
+// followed by every key and value prefixed by their length, all lengths being varints. The keys and the values
+// are encoded by the codecs of WithCodec, by default strings, byte slices, booleans, integers, floats and
+// types implementing encoding.BinaryMarshaler are supported. The entries are read from a Snapshot.
+// example
+//
+//	data, err := m.MarshalBinary()
+//	err = os.WriteFile("cache.bin", data, 0o600)
+func (s *SafeMap[k, v]) MarshalBinary() ([]byte, error) {
+	return s.load().marshalBinary(s.Snapshot().items)
+}
+
+// marshalBinary encodes items in the format of MarshalBinary.
+func (c *core[k, v]) marshalBinary(items map[k]v) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.writeEntries(&buf, items); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeEntries writes items to w in the format of MarshalBinary, one entry at a time.
+func (c *core[k, v]) writeEntries(w io.Writer, items map[k]v) error {
+	keys, values := c.codecs()
+	bw := bufio.NewWriter(w)
+	buf := binary.AppendUvarint([]byte{binaryVersion}, uint64(len(items)))
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+
+	var field []byte
+	for key, val := range items {
+		var err error
+		buf = buf[:0]
+		if field, err = keys.Append(field[:0], key); err != nil {
+			return err
+		}
+		buf = appendField(buf, field)
+		if field, err = values.Append(field[:0], val); err != nil {
+			return err
+		}
+		buf = appendField(buf, field)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// appendField appends field to buf, prefixed by its length.
+func appendField(buf, field []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(field)))
+	return append(buf, field...)
+}
+
+// readEntries reads entries in the format of MarshalBinary from r, calling fn with every entry.
+// It stops after the last entry, without reading further.
+func (c *core[k, v]) readEntries(r interface {
+	io.Reader
+	io.ByteReader
+}, fn func(key k, val v)) error {
+	keys, values := c.codecs()
+	version, err := r.ReadByte()
+	if err != nil || version != binaryVersion {
+		return errCorrupt
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return errCorrupt
+	}
+
+	// the fields are copied as they arrive, a corrupt length doesn't allocate a large buffer upfront
+	var field bytes.Buffer
+	next := func() ([]byte, error) {
+		length, err := binary.ReadUvarint(r)
+		if err != nil || length > math.MaxInt64 {
+			return nil, errCorrupt
+		}
+		field.Reset()
+		if _, err := io.CopyN(&field, r, int64(length)); err != nil {
+			return nil, errCorrupt
+		}
+		return field.Bytes(), nil
+	}
+
+	for range n {
+		data, err := next()
+		if err != nil {
+			return err
+		}
+		key, err := keys.Decode(data)
+		if err != nil {
+			return err
+		}
+		if data, err = next(); err != nil {
+			return err
+		}
+		val, err := values.Decode(data)
+		if err != nil {
+			return err
+		}
+		fn(key, val)
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes entries encoded by MarshalBinary, with the same codecs, into the SafeMap.
+// Like UnmarshalJSON, the existing entries are kept and the decoded ones are set in a single operation.
+func (s *SafeMap[k, v]) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	items := make(map[k]v)
+	if err := s.load().readEntries(r, func(key k, val v) { items[key] = val }); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return errCorrupt
+	}
+
+	s.SetMany(items)
+	return nil
+}
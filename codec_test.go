@@ -0,0 +1,112 @@
+package safemap
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jsonCodec encodes values as JSON.
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Append(buf []byte, val T) ([]byte, error) {
+	data, err := json.Marshal(val)
+	return append(buf, data...), err
+}
+
+func (jsonCodec[T]) Decode(data []byte) (val T, err error) {
+	err = json.Unmarshal(data, &val)
+	return val, err
+}
+
+// roundTrip encodes m with MarshalBinary and decodes it into a new SafeMap created with opts.
+func roundTrip[k comparable, v any](t *testing.T, m *SafeMap[k, v], opts ...Option[k, v]) map[k]v {
+	t.Helper()
+	data, err := m.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := NewSafeMap(opts...)
+	defer decoded.Close()
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	return decoded.GetMap()
+}
+
+func TestSafeMap_MarshalBinary(t *testing.T) {
+	strings := NewSafeMap[string, []byte]()
+	strings.Set("a", []byte("x"))
+	strings.Set("", nil)
+	assert.Equal(t, map[string][]byte{"a": []byte("x"), "": nil}, roundTrip(t, strings))
+
+	ints := NewSafeMap[int8, uint16]()
+	ints.Set(-128, 65535)
+	ints.Set(127, 0)
+	assert.Equal(t, map[int8]uint16{-128: 65535, 127: 0}, roundTrip(t, ints))
+
+	floats := NewSafeMap[float32, bool]()
+	floats.Set(1.5, true)
+	floats.Set(-2, false)
+	assert.Equal(t, map[float32]bool{1.5: true, -2: false}, roundTrip(t, floats))
+
+	// types implementing encoding.BinaryMarshaler are supported
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := NewSafeMap[int64, time.Time]()
+	times.Set(1, now)
+	assert.Equal(t, map[int64]time.Time{1: now}, roundTrip(t, times))
+
+	// the format is compact: a version, the count, and length-prefixed keys and values
+	data, err := ints.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, 1+1+(1+2)+(1+3)+(1+2)+(1+1))
+
+	var empty SafeMap[string, int]
+	data, err = empty.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{binaryVersion, 0}, data)
+}
+
+func TestWithCodec(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	opts := []Option[string, user]{WithCodec[string, user](nil, jsonCodec[user]{})}
+	m := NewSafeMap(opts...)
+	defer m.Close()
+	m.Set("alice", user{Name: "Alice", Age: 30})
+	assert.Equal(t, map[string]user{"alice": {Name: "Alice", Age: 30}}, roundTrip(t, m, opts...))
+
+	// without a codec, the values can't be encoded
+	plain := NewSafeMap[string, user]()
+	defer plain.Close()
+	plain.Set("bob", user{})
+	_, err := plain.MarshalBinary()
+	assert.EqualError(t, err, "safemap: no binary codec for safemap.user, see WithCodec")
+}
+
+func TestSafeMap_UnmarshalBinary(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	m.Set("a", 1)
+	data, err := m.MarshalBinary()
+	assert.NoError(t, err)
+
+	// the existing entries are kept
+	decoded := NewSafeMap[string, int]()
+	defer decoded.Close()
+	decoded.Set("b", 2)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, decoded.GetMap())
+
+	for name, corrupt := range map[string][]byte{
+		"empty":     nil,
+		"version":   {2, 0},
+		"count":     {binaryVersion, 5},
+		"truncated": data[:len(data)-1],
+		"trailing":  append(data, 0),
+		"value":     {binaryVersion, 1, 1, 'a', 0},
+	} {
+		assert.ErrorIs(t, decoded.UnmarshalBinary(corrupt), errCorrupt, name)
+	}
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, decoded.GetMap())
+}
@@ -0,0 +1,65 @@
+package safemap
+
+import "context"
+
+// SetCtx sets the value for the given key in the SafeMap.
+// It returns the context error if ctx is done before the worker accepts the operation, in which case nothing is stored,
+// and ErrClosed if the SafeMap is closed. If ctx is done after the operation was accepted, the value is still stored.
+func (s *SafeMap[k, v]) SetCtx(ctx context.Context, key k, val v) error {
+	_, err := s.sendCtx(ctx, operation[k, v]{
+		op:    "set",
+		key:   key,
+		value: val,
+	})
+	return err
+}
+
+// GetCtx retrieves the value for the given key from the SafeMap, see Get.
+// It returns the context error if ctx is done before the reply is received, and ErrClosed if the SafeMap is closed.
+func (s *SafeMap[k, v]) GetCtx(ctx context.Context, key k) (val v, err error) {
+	reply, err := s.sendCtx(ctx, operation[k, v]{
+		op:  "get",
+		key: key,
+	})
+	if err != nil {
+		return val, err
+	}
+	return s.valueOf(key, reply), nil
+}
+
+// LookupCtx retrieves the value for the given key from the SafeMap and reports whether the key was present.
+// It returns the context error if ctx is done before the reply is received, and ErrClosed if the SafeMap is closed.
+func (s *SafeMap[k, v]) LookupCtx(ctx context.Context, key k) (val v, ok bool, err error) {
+	reply, err := s.sendCtx(ctx, operation[k, v]{
+		op:  "lookup",
+		key: key,
+	})
+	if err != nil {
+		return val, false, err
+	}
+	return reply.value, reply.ok, nil
+}
+
+// DeleteCtx removes the key-value pair for the given key from the SafeMap.
+// It returns the context error if ctx is done before the worker accepts the operation, in which case nothing is deleted,
+// and ErrClosed if the SafeMap is closed. If ctx is done after the operation was accepted, the key is still deleted.
+func (s *SafeMap[k, v]) DeleteCtx(ctx context.Context, key k) error {
+	_, err := s.sendCtx(ctx, operation[k, v]{
+		op:  "delete",
+		key: key,
+	})
+	return err
+}
+
+// ExistCtx checks if the given key exists in the SafeMap.
+// It returns the context error if ctx is done before the reply is received, and ErrClosed if the SafeMap is closed.
+func (s *SafeMap[k, v]) ExistCtx(ctx context.Context, key k) (bool, error) {
+	reply, err := s.sendCtx(ctx, operation[k, v]{
+		op:  "exist",
+		key: key,
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.ok, nil
+}
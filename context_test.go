@@ -0,0 +1,112 @@
+package safemap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Ctx(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	ctx := context.Background()
+
+	assert.NoError(t, m.SetCtx(ctx, "a", 1))
+
+	val, err := m.GetCtx(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	val, ok, err := m.LookupCtx(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+
+	exist, err := m.ExistCtx(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	assert.NoError(t, m.DeleteCtx(ctx, "a"))
+	assert.False(t, m.Exist("a"))
+
+	assert.NoError(t, m.Close())
+	assert.ErrorIs(t, m.SetCtx(ctx, "a", 1), ErrClosed)
+	_, err = m.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestSafeMap_CtxCancelled(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, m.SetCtx(ctx, "a", 1), context.Canceled)
+	assert.False(t, m.Exist("a"))
+
+	// keep the worker busy so the next operation cannot be accepted
+	release := make(chan struct{})
+	go m.Update("busy", func(old int, exists bool) (int, bool) {
+		<-release
+		return old, exists
+	})
+	assert.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := m.GetCtx(ctx, "a")
+		return err != nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, m.SetCtx(context.Background(), "a", 1))
+	assert.Equal(t, 1, m.Get("a"))
+}
+
+func TestSafeMap_CtxReadConcurrency(t *testing.T) {
+	m := NewSafeMap(WithReadConcurrency[string, int](2))
+	m.Set("a", 1)
+
+	val, err := m.GetCtx(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSafeMap_CtxAbandonedReply(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	for i := range 100 {
+		m.Set(i, i)
+	}
+
+	// callers giving up after their operation was accepted must not leak
+	// a late reply into the reply channel of another operation
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := (w*500 + i) % 100
+				if w%2 == 0 {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%3)*time.Microsecond)
+					m.GetCtx(ctx, key)
+					cancel()
+					continue
+				}
+				assert.Equal(t, key, m.Get(key))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+package safemap
+
+// Number is the constraint of the value types of a SafeCounterMap.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// SafeCounterMap is a SafeMap of numbers adding atomic counter operations to the methods of SafeMap.
+// The zero value is an empty map ready to use, and like a SafeMap, a SafeCounterMap must not be copied after first use.
+// example
+//
+//	var hits SafeCounterMap[string, int]
+//	hits.Increment("/index")
+//	hits.Add("/index", 2)
+//	fmt.Println(hits.Get("/index")) // 3
+type SafeCounterMap[k comparable, v Number] struct {
+	SafeMap[k, v]
+}
+
+// NewSafeCounterMap creates a SafeCounterMap with the given options.
+// example
+//
+//	hits := NewSafeCounterMap[string, int](WithDefaultTTL[string, int](time.Minute))
+func NewSafeCounterMap[k comparable, v Number](opts ...Option[k, v]) *SafeCounterMap[k, v] {
+	m := &SafeCounterMap[k, v]{}
+	m.configure(opts)
+	return m
+}
+
+// Add adds delta to the value of the given key, an absent key counting as zero, and returns the new value.
+// The read and the write happen atomically in the worker goroutine, so concurrent calls never lose an update.
+func (m *SafeCounterMap[k, v]) Add(key k, delta v) v {
+	val, _ := m.Update(key, func(old v, _ bool) (v, bool) {
+		return old + delta, true
+	})
+	return val
+}
+
+// Increment adds one to the value of the given key and returns the new value.
+func (m *SafeCounterMap[k, v]) Increment(key k) v {
+	return m.Add(key, 1)
+}
+
+// Decrement subtracts one from the value of the given key and returns the new value.
+// Unsigned values wrap around below zero.
+func (m *SafeCounterMap[k, v]) Decrement(key k) v {
+	val, _ := m.Update(key, func(old v, _ bool) (v, bool) {
+		return old - 1, true
+	})
+	return val
+}
+
+// Total returns the sum of all the values of the SafeCounterMap.
+func (m *SafeCounterMap[k, v]) Total() v {
+	var total v
+	for val := range m.Snapshot().Values() {
+		total += val
+	}
+	return total
+}
@@ -0,0 +1,44 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeCounterMap(t *testing.T) {
+	m := NewSafeCounterMap[string, int]()
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				m.Increment("hits")
+				m.Add("bytes", 3)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8000, m.Get("hits"))
+	assert.Equal(t, 24000, m.Get("bytes"))
+	assert.Equal(t, 7999, m.Decrement("hits"))
+	assert.Equal(t, -1, m.Decrement("absent"))
+	assert.Equal(t, 8000+24000-2, m.Total())
+}
+
+func TestSafeCounterMap_ZeroValue(t *testing.T) {
+	var m SafeCounterMap[string, float64]
+	defer m.Close()
+
+	assert.Equal(t, 1.5, m.Add("a", 1.5))
+	assert.Equal(t, 2.5, m.Increment("a"))
+
+	var u SafeCounterMap[string, uint8]
+	defer u.Close()
+	assert.Equal(t, uint8(255), u.Decrement("a"), "unsigned values wrap around")
+}
@@ -0,0 +1,57 @@
+package safemap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// MarshalJSON encodes the entries of the SafeMap as a JSON object, like a map[k]v.
+// The keys must be strings, integers or implement encoding.TextMarshaler.
+// The entries are read from a Snapshot, so they are consistent even while writers continue.
+// example
+//
+//	type Config struct {
+//		Limits *SafeMap[string, int] `json:"limits"`
+//	}
+//	data, err := json.Marshal(cfg)
+func (s *SafeMap[k, v]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Snapshot().items)
+}
+
+// UnmarshalJSON decodes a JSON object into the SafeMap. Like for a map[k]v, the existing entries are kept
+// and the decoded ones are set in a single operation, see SetMany. A zero SafeMap is ready to be decoded into.
+// example
+//
+//	var limits SafeMap[string, int]
+//	err := json.Unmarshal([]byte(`{"requests":100}`), &limits)
+func (s *SafeMap[k, v]) UnmarshalJSON(data []byte) error {
+	var items map[k]v
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	s.SetMany(items)
+	return nil
+}
+
+// GobEncode encodes the entries of the SafeMap with encoding/gob, like a map[k]v, so a SafeMap can be sent
+// over net/rpc or stored in gob based caches. The entries are read from a Snapshot, taken by the worker,
+// so the encoding is consistent even while writers continue.
+func (s *SafeMap[k, v]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.Snapshot().items); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode decodes entries encoded by GobEncode into the SafeMap. Like for a map[k]v, the existing entries are kept
+// and the decoded ones are set in a single operation, see SetMany. A zero SafeMap is ready to be decoded into.
+func (s *SafeMap[k, v]) GobDecode(data []byte) error {
+	var items map[k]v
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+	s.SetMany(items)
+	return nil
+}
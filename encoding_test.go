@@ -0,0 +1,74 @@
+package safemap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_JSON(t *testing.T) {
+	type config struct {
+		Name   string                `json:"name"`
+		Limits *SafeMap[string, int] `json:"limits"`
+	}
+
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	m.Set("requests", 100)
+	m.Set("burst", 10)
+
+	data, err := json.Marshal(config{Name: "api", Limits: m})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"api","limits":{"burst":10,"requests":100}}`, string(data))
+
+	var decoded config
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	defer decoded.Limits.Close()
+	assert.Equal(t, map[string]int{"requests": 100, "burst": 10}, decoded.Limits.GetMap())
+
+	// the existing entries are kept, like for a map
+	var limits SafeMap[string, int]
+	defer limits.Close()
+	limits.Set("a", 1)
+	assert.NoError(t, json.Unmarshal([]byte(`{"b":2}`), &limits))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, limits.GetMap())
+	assert.Error(t, json.Unmarshal([]byte(`{"b":"x"}`), &limits))
+
+	empty, err := json.Marshal(NewSafeMap[int, string]())
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(empty))
+}
+
+func TestSafeMap_Gob(t *testing.T) {
+	type session struct {
+		User  string
+		Cache *SafeMap[string, []int]
+	}
+
+	m := NewSafeMap[string, []int]()
+	defer m.Close()
+	m.Set("a", []int{1, 2})
+	m.Set("b", nil)
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(session{User: "alice", Cache: m}))
+
+	var decoded session
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	defer decoded.Cache.Close()
+	assert.Equal(t, "alice", decoded.User)
+	assert.Equal(t, map[string][]int{"a": {1, 2}, "b": nil}, decoded.Cache.GetMap())
+
+	// the existing entries are kept, like for a map
+	data, err := m.GobEncode()
+	assert.NoError(t, err)
+	var cache SafeMap[string, []int]
+	defer cache.Close()
+	cache.Set("c", []int{3})
+	assert.NoError(t, cache.GobDecode(data))
+	assert.Len(t, cache.GetMap(), 3)
+	assert.Error(t, cache.GobDecode([]byte("invalid")))
+}
@@ -0,0 +1,266 @@
+package safemap
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionReason tells why an entry was removed from a SafeMap, see WithOnEvict.
+type EvictionReason int
+
+const (
+	// EvictionCapacity reports an entry evicted to keep the SafeMap within WithMaxEntries or WithMaxBytes.
+	EvictionCapacity EvictionReason = iota + 1
+	// EvictionExpired reports an entry removed because its TTL elapsed.
+	EvictionExpired
+	// EvictionDeleted reports an entry removed explicitly, such as with Delete, DeleteFunc or Clear.
+	EvictionDeleted
+)
+
+type (
+	// EvictionPolicy chooses the entry to evict once a SafeMap bounded with WithMaxEntries or WithMaxBytes is full,
+	// see WithEvictionPolicy. NewLRU and NewLFU return the built-in policies.
+	//
+	// The SafeMap reports every use and removal of its entries to the policy, which then only has to track keys.
+	// Its methods are called by the worker goroutine, or under the write lock with WithMutex, so they are never called
+	// concurrently and must not use the SafeMap.
+	EvictionPolicy[k comparable] interface {
+		// RecordInsert records that the value of key was written, key is added if it isn't tracked yet.
+		RecordInsert(key k)
+		// RecordAccess records that the value of key was read with Get, Lookup or GetMany.
+		// It is only called for keys present in the SafeMap.
+		RecordAccess(key k)
+		// Remove stops tracking key, which was evicted or removed from the SafeMap.
+		Remove(key k)
+		// Victim returns the key to evict next, it must be a tracked key.
+		// The ok result is false when no key is tracked.
+		Victim() (key k, ok bool)
+	}
+
+	// policyCloner is implemented by the built-in policies, it lets Clone keep the state of the policy.
+	policyCloner[k comparable] interface {
+		clone() EvictionPolicy[k]
+	}
+
+	// lru evicts the least recently used key. The zero value is empty and ready to use.
+	lru[k comparable] struct {
+		// order lists the keys from the most to the least recently used.
+		order list.List
+		byKey map[k]*list.Element
+	}
+
+	// lfu evicts the least frequently used key, the least recently used one among keys used as often.
+	// It is a min-heap of the keys ordered by number of uses. The zero value is empty and ready to use.
+	// It implements heap.Interface, use its policy methods instead.
+	lfu[k comparable] struct {
+		heap  []*frequency[k]
+		byKey map[k]*frequency[k]
+
+		// tick orders the uses, it breaks the ties between keys used as often.
+		tick uint64
+	}
+
+	// frequency counts the uses of a key tracked by lfu.
+	frequency[k comparable] struct {
+		key      k
+		uses     uint64
+		lastUsed uint64
+
+		// index is the position of the frequency in the heap.
+		index int
+	}
+)
+
+// String returns the name of the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionExpired:
+		return "expired"
+	case EvictionDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// NewLRU returns a policy evicting the least recently used key, the default policy of a bounded SafeMap.
+// example
+//
+//	m := NewSafeMap(WithMaxEntries[string, int](1000), WithEvictionPolicy[string, int](NewLRU[string]))
+func NewLRU[k comparable]() EvictionPolicy[k] {
+	return new(lru[k])
+}
+
+// NewLFU returns a policy evicting the least frequently used key, and the least recently used one among keys used as often.
+// example
+//
+//	m := NewSafeMap(WithMaxEntries[string, int](1000), WithEvictionPolicy[string, int](NewLFU[string]))
+func NewLFU[k comparable]() EvictionPolicy[k] {
+	return new(lfu[k])
+}
+
+// newPolicy returns the eviction policy selected by cfg, or nil if the SafeMap is unbounded.
+func newPolicy[k comparable, v any](cfg config[k, v]) EvictionPolicy[k] {
+	switch {
+	case !cfg.bounded():
+		return nil
+	case cfg.policy != nil:
+		return cfg.policy()
+	default:
+		return NewLRU[k]()
+	}
+}
+
+func (l *lru[k]) RecordInsert(key k) {
+	if el, ok := l.byKey[key]; ok {
+		l.order.MoveToFront(el)
+		return
+	}
+
+	if l.byKey == nil {
+		l.byKey = make(map[k]*list.Element)
+	}
+	l.byKey[key] = l.order.PushFront(key)
+}
+
+func (l *lru[k]) RecordAccess(key k) {
+	if el, ok := l.byKey[key]; ok {
+		l.order.MoveToFront(el)
+	}
+}
+
+func (l *lru[k]) Remove(key k) {
+	if el, ok := l.byKey[key]; ok {
+		l.order.Remove(el)
+		delete(l.byKey, key)
+	}
+}
+
+func (l *lru[k]) Victim() (key k, ok bool) {
+	el := l.order.Back()
+	if el == nil {
+		return key, false
+	}
+	return el.Value.(k), true
+}
+
+func (l *lru[k]) clone() EvictionPolicy[k] {
+	clone := new(lru[k])
+	for el := l.order.Back(); el != nil; el = el.Prev() {
+		clone.RecordInsert(el.Value.(k))
+	}
+	return clone
+}
+
+// Len implements heap.Interface.
+func (l *lfu[k]) Len() int {
+	return len(l.heap)
+}
+
+// Less implements heap.Interface.
+func (l *lfu[k]) Less(i, j int) bool {
+	a, b := l.heap[i], l.heap[j]
+	if a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+// Swap implements heap.Interface.
+func (l *lfu[k]) Swap(i, j int) {
+	l.heap[i], l.heap[j] = l.heap[j], l.heap[i]
+	l.heap[i].index = i
+	l.heap[j].index = j
+}
+
+// Push implements heap.Interface.
+func (l *lfu[k]) Push(x any) {
+	f := x.(*frequency[k])
+	f.index = len(l.heap)
+	l.heap = append(l.heap, f)
+}
+
+// Pop implements heap.Interface.
+func (l *lfu[k]) Pop() any {
+	last := len(l.heap) - 1
+	f := l.heap[last]
+	l.heap[last] = nil
+	l.heap = l.heap[:last]
+	return f
+}
+
+func (l *lfu[k]) RecordInsert(key k) {
+	if _, ok := l.byKey[key]; ok {
+		l.RecordAccess(key)
+		return
+	}
+
+	if l.byKey == nil {
+		l.byKey = make(map[k]*frequency[k])
+	}
+	l.tick++
+	f := &frequency[k]{key: key, uses: 1, lastUsed: l.tick}
+	l.byKey[key] = f
+	heap.Push(l, f)
+}
+
+func (l *lfu[k]) RecordAccess(key k) {
+	if f, ok := l.byKey[key]; ok {
+		l.tick++
+		f.uses++
+		f.lastUsed = l.tick
+		heap.Fix(l, f.index)
+	}
+}
+
+func (l *lfu[k]) Remove(key k) {
+	if f, ok := l.byKey[key]; ok {
+		heap.Remove(l, f.index)
+		delete(l.byKey, key)
+	}
+}
+
+func (l *lfu[k]) Victim() (key k, ok bool) {
+	if len(l.heap) == 0 {
+		return key, false
+	}
+	return l.heap[0].key, true
+}
+
+func (l *lfu[k]) clone() EvictionPolicy[k] {
+	clone := &lfu[k]{
+		heap:  make([]*frequency[k], len(l.heap)),
+		byKey: make(map[k]*frequency[k], len(l.heap)),
+		tick:  l.tick,
+	}
+	for i, f := range l.heap {
+		f := *f
+		clone.heap[i] = &f
+		clone.byKey[f.key] = &f
+	}
+	return clone
+}
+
+// evict removes entries chosen by the eviction policy while the SafeMap exceeds its bounds.
+// The caller must hold the write lock.
+func (c *core[k, v]) evict() {
+	var stale *k
+	for c.cfg.maxEntries > 0 && c.store.Len() > c.cfg.maxEntries || c.cfg.maxBytes > 0 && c.bytes > c.cfg.maxBytes {
+		key, ok := c.policy.Victim()
+		if !ok {
+			return
+		}
+		// a victim missing from the map is dropped from the policy and the next one is asked for,
+		// evicting stops if the policy returns it again instead of looping
+		if _, ok := c.store.Get(key); !ok {
+			if stale != nil && *stale == key {
+				return
+			}
+			c.policy.Remove(key)
+			stale = &key
+			continue
+		}
+		c.removeFor(key, EvictionCapacity)
+	}
+}
@@ -0,0 +1,298 @@
+package safemap
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMaxEntries(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":   nil,
+		"mutex":    {WithMutex[string, int]()},
+		"readers":  {WithReadConcurrency[string, int](4)},
+		"snapshot": {WithSnapshotReads[string, int](), WithCopyOnWrite[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(append(opts, WithMaxEntries[string, int](3))...)
+
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Set("c", 3)
+
+			// reading a makes b the least recently used entry
+			assert.Equal(t, 1, m.Get("a"))
+			m.Set("d", 4)
+			assert.Equal(t, map[string]int{"a": 1, "c": 3, "d": 4}, m.GetMap())
+
+			// writing c makes a the least recently used entry
+			m.Set("c", 30)
+			m.Set("e", 5)
+			assert.Equal(t, map[string]int{"c": 30, "d": 4, "e": 5}, m.GetMap())
+
+			// a deleted entry makes room without evicting another one
+			m.SetMany(map[string]int{"f": 6})
+			m.Delete("c")
+			m.Set("g", 7)
+			assert.Equal(t, map[string]int{"e": 5, "f": 6, "g": 7}, m.GetMap())
+		})
+	}
+}
+
+func TestWithLFU(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](3), WithLFU[string, int]())
+
+	m.Set("hot", 1)
+	for range 10 {
+		m.Get("hot")
+	}
+	m.Set("a", 2)
+	m.Get("a")
+
+	// a scan over cold keys keeps evicting the latest cold key instead of the hot ones
+	for i := range 10 {
+		m.Set(fmt.Sprint("cold", i), i)
+	}
+	assert.Equal(t, map[string]int{"hot": 1, "a": 2, "cold9": 9}, m.GetMap())
+
+	clone := m.Clone()
+	clone.Set("b", 3)
+	assert.Equal(t, map[string]int{"hot": 1, "a": 2, "b": 3}, clone.GetMap())
+}
+
+func TestWithMaxBytes(t *testing.T) {
+	size := func(key string, val string) int { return len(val) }
+	m := NewSafeMap(WithMaxBytes(10, size))
+
+	m.Set("a", "aaaa")
+	m.Set("b", "bbbb")
+	m.Get("a")
+
+	// b is the least recently used entry
+	m.Set("c", "cc")
+	m.Set("a", "aaaaaa")
+	assert.Equal(t, map[string]string{"a": "aaaaaa", "c": "cc"}, m.GetMap())
+
+	// an entry larger than the budget is not stored
+	m.Set("d", "ddddddddddd")
+	assert.False(t, m.Exist("d"))
+	assert.Equal(t, 8, m.bytes)
+
+	m.Delete("a")
+	m.Set("e", "eeeeeeee")
+	assert.Equal(t, map[string]string{"c": "cc", "e": "eeeeeeee"}, m.GetMap())
+
+	// the clone accounts for the entries it holds
+	clone := m.Clone()
+	assert.Equal(t, 10, clone.bytes)
+	clone.Set("f", "f")
+	assert.Equal(t, map[string]string{"e": "eeeeeeee", "f": "f"}, clone.GetMap())
+
+	bounded := NewSafeMap(WithMaxBytes(10, size), WithMaxEntries[string, string](2))
+	bounded.Set("a", "a")
+	bounded.Set("b", "b")
+	bounded.Set("c", "c")
+	assert.Equal(t, map[string]string{"b": "b", "c": "c"}, bounded.GetMap())
+}
+
+func TestWithOnEvict(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		removed []string
+	)
+	clock := newFakeClock()
+	m := NewSafeMap(withClock[string, int](clock.Now), WithMaxEntries[string, int](2), WithOnEvict(func(key string, val int, reason EvictionReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, fmt.Sprint(key, "=", val, " ", reason))
+	}))
+	defer m.Close()
+
+	m.SetWithTTL("a", 1, time.Minute)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Set("b", 20)
+	m.Delete("b")
+	m.Delete("z")
+	m.SetWithTTL("d", 4, time.Minute)
+	clock.Advance(time.Minute)
+	m.Set("e", 5)
+	m.Clear()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(removed) == 5
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a=1 capacity", "b=20 deleted", "d=4 expired"}, removed[:3])
+	// Clear removes the entries in no particular order
+	assert.ElementsMatch(t, []string{"c=3 deleted", "e=5 deleted"}, removed[3:])
+}
+
+func TestEvictionReason(t *testing.T) {
+	assert.Equal(t, "capacity", EvictionCapacity.String())
+	assert.Equal(t, "expired", EvictionExpired.String())
+	assert.Equal(t, "deleted", EvictionDeleted.String())
+	assert.Equal(t, "unknown", EvictionReason(0).String())
+}
+
+// fifoPolicy evicts the oldest inserted key, it is a custom EvictionPolicy.
+type fifoPolicy struct {
+	keys []string
+}
+
+func (p *fifoPolicy) RecordInsert(key string) {
+	if !slices.Contains(p.keys, key) {
+		p.keys = append(p.keys, key)
+	}
+}
+
+func (p *fifoPolicy) RecordAccess(key string) {}
+
+func (p *fifoPolicy) Remove(key string) {
+	p.keys = slices.DeleteFunc(p.keys, func(k string) bool { return k == key })
+}
+
+func (p *fifoPolicy) Victim() (string, bool) {
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	return p.keys[0], true
+}
+
+func TestWithEvictionPolicy(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](2), WithEvictionPolicy[string, int](func() EvictionPolicy[string] {
+		return new(fifoPolicy)
+	}))
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Get("a")
+	m.Set("a", 10)
+	m.Set("c", 3)
+	assert.Equal(t, map[string]int{"b": 2, "c": 3}, m.GetMap())
+
+	// the clone replays its keys into a new policy
+	clone := m.Clone()
+	clone.Set("d", 4)
+	assert.Equal(t, 2, clone.Length())
+	assert.True(t, clone.Exist("d"))
+	assert.Equal(t, map[string]int{"b": 2, "c": 3}, m.GetMap())
+}
+
+// lostPolicy returns a victim the SafeMap doesn't hold.
+type lostPolicy struct{ fifoPolicy }
+
+func (p *lostPolicy) Victim() (string, bool) {
+	return "lost", true
+}
+
+func TestWithEvictionPolicyUnknownVictim(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](1), WithEvictionPolicy[string, int](func() EvictionPolicy[string] {
+		return new(lostPolicy)
+	}))
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	assert.Equal(t, 2, m.Length())
+}
+
+// stalePolicy returns victims the SafeMap doesn't hold before the keys it holds.
+type stalePolicy struct {
+	fifoPolicy
+	stale []string
+}
+
+func (p *stalePolicy) Remove(key string) {
+	p.stale = slices.DeleteFunc(p.stale, func(k string) bool { return k == key })
+	p.fifoPolicy.Remove(key)
+}
+
+func (p *stalePolicy) Victim() (string, bool) {
+	if len(p.stale) > 0 {
+		return p.stale[0], true
+	}
+	return p.fifoPolicy.Victim()
+}
+
+func TestWithEvictionPolicyStaleVictim(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](1), WithEvictionPolicy[string, int](func() EvictionPolicy[string] {
+		return &stalePolicy{stale: []string{"x", "y"}}
+	}))
+
+	// the stale victims are skipped and the map is brought back within its bound
+	m.Set("a", 1)
+	m.Set("b", 2)
+	assert.Equal(t, map[string]int{"b": 2}, m.GetMap())
+}
+
+func TestWithMaxEntriesClone(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](2))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Get("a")
+
+	// the clone keeps the order of use
+	clone := m.Clone()
+	clone.Set("c", 3)
+	assert.Equal(t, map[string]int{"a": 1, "c": 3}, clone.GetMap())
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, m.GetMap())
+}
+
+// victims drains p and returns the keys in the order they are evicted.
+func victims(p EvictionPolicy[string]) []string {
+	var keys []string
+	for key, ok := p.Victim(); ok; key, ok = p.Victim() {
+		keys = append(keys, key)
+		p.Remove(key)
+	}
+	return keys
+}
+
+func TestLRU(t *testing.T) {
+	var l lru[string]
+	_, ok := l.Victim()
+	assert.False(t, ok)
+
+	l.RecordInsert("a")
+	l.RecordInsert("b")
+	l.RecordInsert("c")
+	l.RecordInsert("d")
+	l.RecordAccess("a")
+	l.RecordAccess("z")
+	l.Remove("c")
+	l.Remove("z")
+	l.RecordInsert("b")
+
+	clone := l.clone()
+	assert.Equal(t, []string{"d", "a", "b"}, victims(&l))
+	assert.Equal(t, []string{"d", "a", "b"}, victims(clone))
+}
+
+func TestLFU(t *testing.T) {
+	var l lfu[string]
+	_, ok := l.Victim()
+	assert.False(t, ok)
+
+	l.RecordInsert("a")
+	l.RecordInsert("b")
+	l.RecordInsert("c")
+	l.RecordInsert("d")
+	l.RecordAccess("a")
+	l.RecordAccess("a")
+	l.RecordInsert("c")
+	l.RecordAccess("z")
+	l.Remove("b")
+	l.Remove("z")
+
+	// d and e are used as often, d was used first
+	l.RecordInsert("e")
+	clone := l.clone()
+	clone.RecordAccess("d")
+	assert.Equal(t, []string{"d", "e", "c", "a"}, victims(&l))
+	assert.Equal(t, []string{"e", "c", "d", "a"}, victims(clone))
+}
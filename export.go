@@ -0,0 +1,52 @@
+package safemap
+
+import (
+	"bufio"
+	"io"
+)
+
+// importChunk is the number of entries Import sets in a single operation.
+const importChunk = 1024
+
+// Export writes the entries of the SafeMap to w in the format of MarshalBinary, one entry at a time,
+// without building the whole encoding in memory. The entries are read from a Snapshot, so they are not copied
+// unless the SafeMap is written while they are exported, in which case the next write copies them once.
+// example
+//
+//	f, err := os.Create("dump.bin")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	return m.Export(f)
+func (s *SafeMap[k, v]) Export(w io.Writer) error {
+	return s.load().writeEntries(w, s.Snapshot().items)
+}
+
+// Import reads entries written by Export, MarshalBinary or Save from r and sets them in the SafeMap,
+// keeping the existing entries. The entries are decoded as they are read and set by chunks of a thousand,
+// so memory stays bounded whatever the size of the input. Unlike UnmarshalBinary, the import is not atomic:
+// other operations may observe it half done, and if the input turns out to be invalid, the entries before
+// the error are already set. Import stops after the last entry, r may hold further data.
+// example
+//
+//	f, err := os.Open("dump.bin")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	return m.Import(f)
+func (s *SafeMap[k, v]) Import(r io.Reader) error {
+	chunk := make(map[k]v, importChunk)
+	err := s.load().readEntries(bufio.NewReader(r), func(key k, val v) {
+		chunk[key] = val
+		if len(chunk) == importChunk {
+			s.SetMany(chunk)
+			clear(chunk)
+		}
+	})
+	if len(chunk) > 0 {
+		s.SetMany(chunk)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package safemap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Export(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	for i := range 3000 {
+		m.Set(fmt.Sprint(i), i)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, m.Export(&buf))
+	data, err := m.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), buf.Len(), "the same format as MarshalBinary")
+
+	imported := NewSafeMap[string, int]()
+	defer imported.Close()
+	imported.Set("existing", -1)
+	assert.NoError(t, imported.Import(&buf))
+	assert.Equal(t, 3001, imported.Length())
+	assert.Equal(t, 2999, imported.Get("2999"))
+	assert.Equal(t, -1, imported.Get("existing"))
+
+	// a file written by Save can be imported
+	path := filepath.Join(t.TempDir(), "dump.bin")
+	assert.NoError(t, m.Save(path))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	fromFile := NewSafeMap[string, int]()
+	defer fromFile.Close()
+	assert.NoError(t, fromFile.Import(f))
+	assert.Equal(t, m.GetMap(), fromFile.GetMap())
+}
+
+func TestSafeMap_Import(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	for i := range 2000 {
+		m.Set(fmt.Sprint(i), i)
+	}
+	data, err := m.MarshalBinary()
+	assert.NoError(t, err)
+
+	// the entries before an invalid input are already set
+	imported := NewSafeMap[string, int]()
+	defer imported.Close()
+	assert.ErrorIs(t, imported.Import(bytes.NewReader(data[:len(data)/2])), errCorrupt)
+	assert.Greater(t, imported.Length(), 0)
+	assert.Less(t, imported.Length(), 2000)
+
+	assert.ErrorIs(t, imported.Import(bytes.NewReader(nil)), errCorrupt)
+
+	// a field length beyond the input doesn't allocate it
+	assert.ErrorIs(t, imported.Import(bytes.NewReader([]byte{binaryVersion, 1, 0xff, 0xff, 0xff, 0xff, 0x0f})), errCorrupt)
+}
@@ -0,0 +1,74 @@
+package safemap
+
+import "context"
+
+// Future is the pending result of an operation issued without waiting for the worker, such as GetFuture.
+// Many futures can be issued back to back and resolved later, instead of paying one round trip after the other.
+type Future[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// newFuture runs resolve in its own goroutine and returns a Future holding its result.
+func newFuture[T any](resolve func() (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	go func() {
+		f.val, f.err = resolve()
+		close(f.done)
+	}()
+	return f
+}
+
+// Done returns a channel that is closed once the result of the Future is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the result of the Future. It returns ErrClosed if the SafeMap was closed before the operation was applied.
+// If ctx is done first, Wait returns the context error, the operation still completes and a later Wait returns its result.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// GetFuture retrieves the value for the given key from the SafeMap without waiting for the worker.
+// The returned Future resolves to the value, or the zero value if the key is not present.
+// example
+//
+//	futures := make([]*Future[int], len(keys))
+//	for i, key := range keys {
+//		futures[i] = m.GetFuture(key)
+//	}
+//	for _, f := range futures {
+//		val, err := f.Wait(ctx)
+//		...
+//	}
+func (s *SafeMap[k, v]) GetFuture(key k) *Future[v] {
+	c := s.load()
+	return newFuture(func() (v, error) {
+		reply, err := c.sendCtx(context.Background(), operation[k, v]{
+			op:  "get",
+			key: key,
+		})
+		return reply.value, err
+	})
+}
+
+// ExistFuture checks if the given key exists in the SafeMap without waiting for the worker.
+// The returned Future resolves to whether the key is present.
+func (s *SafeMap[k, v]) ExistFuture(key k) *Future[bool] {
+	c := s.load()
+	return newFuture(func() (bool, error) {
+		reply, err := c.sendCtx(context.Background(), operation[k, v]{
+			op:  "exist",
+			key: key,
+		})
+		return reply.ok, err
+	})
+}
@@ -0,0 +1,60 @@
+package safemap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Future(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	for i := range 10 {
+		m.Set(i, i*10)
+	}
+
+	futures := make([]*Future[int], 20)
+	for i := range futures {
+		futures[i] = m.GetFuture(i)
+	}
+	for i, f := range futures {
+		val, err := f.Wait(context.Background())
+		assert.NoError(t, err)
+		if i < 10 {
+			assert.Equal(t, i*10, val)
+		} else {
+			assert.Zero(t, val)
+		}
+	}
+
+	exist := m.ExistFuture(3)
+	<-exist.Done()
+	found, err := exist.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	// keep the worker busy so the future cannot resolve before the context expires
+	started, release := make(chan struct{}), make(chan struct{})
+	go m.Update(-1, func(old int, exists bool) (int, bool) {
+		close(started)
+		<-release
+		return old, exists
+	})
+	<-started
+
+	f := m.GetFuture(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = f.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	val, err := f.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 10, val)
+
+	assert.NoError(t, m.Close())
+	_, err = m.GetFuture(1).Wait(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
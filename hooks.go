@@ -0,0 +1,52 @@
+package safemap
+
+import (
+	"context"
+	"hash/maphash"
+)
+
+type (
+	// Hooks instruments the operations of a SafeMap, see WithHooks.
+	// It lets spans of a tracing library such as OpenTelemetry, or custom timing, be attached to every operation.
+	Hooks interface {
+		// OnOpStart is called when a method of the SafeMap starts an operation, in the calling goroutine.
+		// ctx is the context passed to the method, or context.Background for methods without one.
+		// The returned context is passed to OnOpEnd, for example carrying the span started for the operation.
+		OnOpStart(ctx context.Context, op OpInfo) context.Context
+
+		// OnOpEnd is called once the operation completed, with the context returned by OnOpStart
+		// and the error the operation failed with, such as ErrClosed or the error of a context that is done.
+		OnOpEnd(ctx context.Context, op OpInfo, err error)
+	}
+
+	// OpInfo describes an operation reported to Hooks.
+	OpInfo struct {
+		// Name is the name of the operation, such as "get" or "set".
+		Name string
+
+		// KeyHash is a hash of the key of the operation, it lets operations be correlated by key without exposing keys.
+		// It is only computed with WithKeyHashing and for operations on a single key, it is zero otherwise.
+		// Hashes are consistent within a SafeMap, but differ between SafeMaps and between runs.
+		KeyHash uint64
+	}
+)
+
+// opInfo describes op for the hooks.
+func (c *core[k, v]) opInfo(op operation[k, v]) OpInfo {
+	info := OpInfo{Name: op.op}
+	if c.cfg.keyHashing && hasKey(op.op) {
+		info.KeyHash = maphash.Comparable(c.seed, op.key)
+	}
+	return info
+}
+
+// hasKey reports whether the operation applies to a single key.
+func hasKey(op string) bool {
+	switch op {
+	case "get", "lookup", "exist", "set", "delete", "getOrSet", "getAndDelete", "swap", "compareAndSwap", "compareAndDelete",
+		"update", "upsert", "setIfAbsent", "setIfPresent", "getTTL", "touch", "expire", "getVersioned", "setIfVersion",
+		"deleteIfVersion", "lock", "unlock", "refreshed", "refreshFailed", "setUnique":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package safemap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type spanKey struct{}
+
+// recordingHooks records the operations it is notified of.
+type recordingHooks struct {
+	mu  sync.Mutex
+	ops []string
+}
+
+func (h *recordingHooks) OnOpStart(ctx context.Context, op OpInfo) context.Context {
+	return context.WithValue(ctx, spanKey{}, op.Name)
+}
+
+func (h *recordingHooks) OnOpEnd(ctx context.Context, op OpInfo, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ops = append(h.ops, fmt.Sprint(ctx.Value(spanKey{}), " ", op.KeyHash != 0, " ", err))
+}
+
+func TestWithHooks(t *testing.T) {
+	hooks := new(recordingHooks)
+	m := NewSafeMap(WithHooks[string, int](hooks))
+
+	m.Set("a", 1)
+	m.Length()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := m.GetCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+	m.SetAsync("b", 2)
+	m.TryGet("a")
+	m.Close()
+	m.Get("a")
+
+	assert.Equal(t, []string{
+		"set false <nil>",
+		"getLen false <nil>",
+		"get false context canceled",
+		"get false safemap: closed",
+	}, hooks.ops)
+}
+
+func TestWithKeyHashing(t *testing.T) {
+	hooks := new(recordingHooks)
+	m := NewSafeMap(WithHooks[string, int](hooks), WithKeyHashing[string, int]())
+
+	m.Set("a", 1)
+	m.Length()
+	assert.Equal(t, []string{"set true <nil>", "getLen false <nil>"}, hooks.ops)
+
+	a := m.opInfo(operation[string, int]{op: "get", key: "a"})
+	assert.Equal(t, OpInfo{Name: "get", KeyHash: a.KeyHash}, a)
+	assert.Equal(t, a, m.opInfo(operation[string, int]{op: "get", key: "a"}))
+	assert.NotEqual(t, a.KeyHash, m.opInfo(operation[string, int]{op: "get", key: "b"}).KeyHash)
+}
+
+func TestWithSlowOpThreshold(t *testing.T) {
+	var slow []string
+	m := NewSafeMap(WithSlowOpThreshold[string, int](20*time.Millisecond, func(op string, elapsed time.Duration) {
+		assert.Greater(t, elapsed, 20*time.Millisecond)
+		slow = append(slow, op)
+	}))
+
+	m.Set("a", 1)
+	m.Update("a", func(old int, exists bool) (int, bool) {
+		time.Sleep(30 * time.Millisecond)
+		return old + 1, true
+	})
+	m.Get("a")
+
+	assert.Equal(t, []string{"update"}, slow)
+}
@@ -0,0 +1,71 @@
+package safemap
+
+// parks holds op back if its key is locked by Do, until the key is unlocked. The caller must hold the write lock.
+// Only the operations on a single key are held back, the unlock of the key never is.
+func (c *core[k, v]) parks(op operation[k, v]) bool {
+	if len(c.locked) == 0 || op.op == "unlock" || !hasKey(op.op) {
+		return false
+	}
+	queue, ok := c.locked[op.key]
+	if ok {
+		c.locked[op.key] = append(queue, op)
+	}
+	return ok
+}
+
+// step applies op and queues its reply, unless the key of op is locked. The caller must hold the write lock.
+// Unlocking a key applies the operations held back in order, until one of them locks the key again.
+func (c *core[k, v]) step(op operation[k, v]) {
+	if c.parks(op) {
+		return
+	}
+
+	var queue []operation[k, v]
+	if op.op == "unlock" {
+		queue = c.locked[op.key]
+		delete(c.locked, op.key)
+		c.locks.Add(-1)
+	}
+
+	reply := c.applyRecover(op)
+	// asynchronous operations have no reply channel, nobody waits for them
+	if op.replyChan != nil {
+		c.pending = append(c.pending, pendingReply[k, v]{replyChan: op.replyChan, reply: reply})
+	}
+
+	for _, parked := range queue {
+		c.step(parked)
+	}
+}
+
+// Do runs fn on the value for the given key while no other operation on the key is applied, and stores its result like Update.
+// fn receives the current value and whether the key exists, and returns the new value and whether to keep it,
+// the key being deleted otherwise. Do returns the resulting value and whether the key is present afterwards.
+//
+// Unlike Update, fn runs in the calling goroutine: the operations on other keys go on while it runs,
+// and the operations on the same key, including other calls to Do, are queued in order until it returns.
+// Operations on several keys at once, such as GetMap, Clear or Txn, are not held back and may observe or modify the key.
+// With WithMutex, fn runs under the lock like Update, holding back every operation.
+// fn must not call the methods of the SafeMap on the same key, which would deadlock.
+// example
+//
+//	m.Do("order-42", func(state State, exists bool) (State, bool) {
+//		return state.Advance(), true // may take a while
+//	})
+func (s *SafeMap[k, v]) Do(key k, fn func(old v, exists bool) (new v, keep bool)) (val v, ok bool) {
+	if s.cfg.mutex {
+		return s.Update(key, fn)
+	}
+
+	current := s.send(operation[k, v]{op: "lock", key: key})
+	var update func(old v, exists bool) (v, bool)
+	defer func() {
+		// a panicking fn releases the key without writing, see the unlock operation
+		reply := s.send(operation[k, v]{op: "unlock", key: key, update: update})
+		val, ok = reply.value, reply.ok
+	}()
+
+	val, keep := fn(current.value, current.ok)
+	update = func(v, bool) (v, bool) { return val, keep }
+	return val, keep
+}
@@ -0,0 +1,89 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Do(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	m.Set("a", 1)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		val, ok := m.Do("a", func(old int, exists bool) (int, bool) {
+			assert.True(t, exists)
+			close(entered)
+			<-release
+			return old + 1, true
+		})
+		assert.True(t, ok)
+		assert.Equal(t, 2, val)
+	}()
+	<-entered
+
+	// the operations on other keys go on, the ones on the key wait for fn
+	m.Set("b", 2)
+	assert.Equal(t, 2, m.Get("b"))
+	got := make(chan int)
+	go func() { got <- m.Get("a") }()
+	select {
+	case <-got:
+		assert.Fail(t, "read the key while it was locked")
+	case <-time.After(20 * time.Millisecond):
+	}
+	m.SetAsync("a", 10)
+
+	close(release)
+	assert.Equal(t, 2, <-got)
+	<-done
+	assert.Eventually(t, func() bool { return m.Get("a") == 10 }, time.Second, time.Millisecond)
+	assert.Zero(t, m.locks.Load())
+}
+
+func TestSafeMap_Do_Serialized(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					m.Do("a", func(old int, exists bool) (int, bool) {
+						time.Sleep(time.Millisecond)
+						return old + 1, true
+					})
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, 20, m.Get("a"))
+
+			val, ok := m.Do("a", func(old int, exists bool) (int, bool) { return 0, false })
+			assert.False(t, ok)
+			assert.Zero(t, val)
+			assert.False(t, m.Exist("a"))
+
+			// a panicking fn releases the key untouched
+			m.Set("b", 1)
+			assert.PanicsWithValue(t, "boom", func() {
+				m.Do("b", func(old int, exists bool) (int, bool) { panic("boom") })
+			})
+			assert.Equal(t, 1, m.Get("b"))
+			m.Set("b", 2)
+			assert.Equal(t, 2, m.Get("b"))
+		})
+	}
+}
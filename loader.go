@@ -0,0 +1,176 @@
+package safemap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// flight is a call of a loader shared by the concurrent GetOrCompute calls for the same key.
+	flight[v any] struct {
+		done chan struct{}
+		val  v
+		err  error
+	}
+
+	// flights tracks the loaders running for every key, and the failures cached with WithNegativeCache.
+	flights[k comparable, v any] struct {
+		mu    sync.Mutex
+		calls map[k]*flight[v]
+
+		// failures holds the errors of the failed loads until their deadline,
+		// the expired ones are dropped once there are sweepAt of them.
+		failures map[k]failure
+		sweepAt  int
+	}
+
+	// failure is a cached error of a loader.
+	failure struct {
+		err      error
+		deadline time.Time
+	}
+)
+
+// failed returns the cached error of the last load of key, if it is still valid at now.
+func (f *flights[k, v]) failed(key k, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fail, ok := f.failures[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(fail.deadline) {
+		delete(f.failures, key)
+		return nil
+	}
+	return fail.err
+}
+
+// fail caches err as the result of loading key at now, for ttl.
+func (f *flights[k, v]) fail(key k, err error, now time.Time, ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures == nil {
+		f.failures = make(map[k]failure)
+	}
+	if len(f.failures) >= f.sweepAt {
+		for key, fail := range f.failures {
+			if !now.Before(fail.deadline) {
+				delete(f.failures, key)
+			}
+		}
+		f.sweepAt = max(2*len(f.failures), 64)
+	}
+	f.failures[key] = failure{err: err, deadline: now.Add(ttl)}
+}
+
+// do calls loader for key, unless a call for key is already running, in which case it waits for its result.
+// A panic in loader is raised again in the goroutine that called it, the others receive it as an error.
+func (f *flights[k, v]) do(key k, loader func(key k) (v, error)) (v, error) {
+	f.mu.Lock()
+	if call, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-call.done
+		return call.val, call.err
+	}
+	if f.calls == nil {
+		f.calls = make(map[k]*flight[v])
+	}
+	call := &flight[v]{done: make(chan struct{})}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("safemap: loader panicked: %v", r)
+			f.finish(key, call)
+			panic(r)
+		}
+		f.finish(key, call)
+	}()
+	call.val, call.err = loader(key)
+	return call.val, call.err
+}
+
+// finish hands the result of call over to the goroutines waiting for it.
+func (f *flights[k, v]) finish(key k, call *flight[v]) {
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+	close(call.done)
+}
+
+// GetOrCompute returns the value for the given key, calling loader to compute and store it if the key is absent.
+// It implements the cache-aside pattern, and deduplicates the loads: concurrent calls for the same absent key
+// wait for a single call of loader and share its result, so a cold key doesn't cause a thundering herd.
+// loader runs in the calling goroutine, without blocking the other operations. An error returned by loader
+// is returned as is, and nothing is stored. If the key is set while loader runs, that value is kept and returned.
+// With WithNegativeCache, the error is also returned for the key without calling loader again for a while.
+// example
+//
+//	user, err := users.GetOrCompute(id, func(id string) (User, error) {
+//		return db.LoadUser(ctx, id)
+//	})
+func (s *SafeMap[k, v]) GetOrCompute(key k, loader func(key k) (v, error)) (v, error) {
+	if val, ok := s.Lookup(key); ok {
+		return val, nil
+	}
+
+	c := s.load()
+	if c.cfg.negativeTTL > 0 {
+		if err := c.flights.failed(key, c.clock()); err != nil {
+			var zero v
+			return zero, err
+		}
+	}
+
+	return c.flights.do(key, func(key k) (v, error) {
+		// the key may have been loaded while waiting for the previous call to finish
+		if val, ok := s.Lookup(key); ok {
+			return val, nil
+		}
+		val, err := loader(key)
+		if err != nil {
+			if c.cfg.negativeTTL > 0 {
+				c.flights.fail(key, err, c.clock(), c.cfg.negativeTTL)
+			}
+			return val, err
+		}
+		val, _ = s.GetOrSet(key, val)
+		return val, nil
+	})
+}
+
+// refresh reloads key in the background if its TTL is about to elapse, see WithRefreshAhead. The caller must hold the write lock.
+func (c *core[k, v]) refresh(key k) {
+	exp, ok := c.expiries.byKey[key]
+	if !ok || exp.deadline.Sub(c.clock()) >= c.cfg.refreshAhead {
+		return
+	}
+	if _, ok := c.refreshing[key]; ok {
+		return
+	}
+	if c.refreshing == nil {
+		c.refreshing = make(map[k]struct{})
+	}
+	c.refreshing[key] = struct{}{}
+
+	// the reloaded value is dropped if the key was written meanwhile, so it never overwrites a newer write
+	ver, _ := c.versionOf(key)
+	loader, ttl := c.cfg.refreshLoader, exp.ttl
+	go func() {
+		// a panicking loader fails the reload instead of the process, and the key can be reloaded again
+		defer func() {
+			if r := recover(); r != nil {
+				c.sendAsync(operation[k, v]{op: "refreshFailed", key: key})
+			}
+		}()
+		val, err := loader(key)
+		if err != nil {
+			c.sendAsync(operation[k, v]{op: "refreshFailed", key: key})
+			return
+		}
+		c.sendAsync(operation[k, v]{op: "refreshed", key: key, value: val, ttl: ttl, version: ver})
+	}()
+}
@@ -0,0 +1,236 @@
+package safemap
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_GetOrCompute(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+			m.Set("a", 1)
+
+			var calls atomic.Int64
+			loader := func(key string) (int, error) {
+				calls.Add(1)
+				if key == "fail" {
+					return 0, errors.New("not found")
+				}
+				time.Sleep(5 * time.Millisecond)
+				return len(key), nil
+			}
+
+			val, err := m.GetOrCompute("a", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, val)
+			assert.Zero(t, calls.Load())
+
+			_, err = m.GetOrCompute("fail", loader)
+			assert.EqualError(t, err, "not found")
+			assert.False(t, m.Exist("fail"))
+
+			// concurrent calls for a missing key share a single load
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					val, err := m.GetOrCompute("abc", loader)
+					assert.NoError(t, err)
+					assert.Equal(t, 3, val)
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, 3, m.Get("abc"))
+			assert.Equal(t, int64(2), calls.Load())
+		})
+	}
+}
+
+func TestSafeMap_GetOrCompute_Shared(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errLoad := errors.New("unavailable")
+	go func() {
+		_, err := m.GetOrCompute("a", func(string) (int, error) {
+			close(started)
+			<-release
+			return 0, errLoad
+		})
+		assert.ErrorIs(t, err, errLoad)
+	}()
+	<-started
+
+	// the waiting calls receive the error of the running load
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.GetOrCompute("a", func(string) (int, error) {
+				assert.Fail(t, "loaded twice")
+				return 0, nil
+			})
+			assert.ErrorIs(t, err, errLoad)
+		}()
+	}
+	assert.Eventually(t, func() bool { return len(m.flights.calls) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// a panic is raised in the loading goroutine and reported to the waiting ones
+	started = make(chan struct{})
+	done := make(chan error)
+	go func() {
+		<-started
+		_, err := m.GetOrCompute("b", func(string) (int, error) { return 1, nil })
+		done <- err
+	}()
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = m.GetOrCompute("b", func(string) (int, error) {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			panic("boom")
+		})
+	})
+	assert.EqualError(t, <-done, "safemap: loader panicked: boom")
+	assert.Empty(t, m.flights.calls)
+}
+
+func TestWithRefreshAhead(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			var calls atomic.Int64
+			release := make(chan struct{})
+			loader := func(key string) (int, error) {
+				calls.Add(1)
+				<-release
+				if key == "fail" {
+					return 0, errors.New("unavailable")
+				}
+				return 2, nil
+			}
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now), WithRefreshAhead(10*time.Second, loader))...)
+			defer m.Close()
+
+			m.SetWithTTL("a", 1, time.Minute)
+			m.SetWithTTL("fail", 1, time.Minute)
+			assert.Equal(t, 1, m.Get("a"))
+			assert.Zero(t, calls.Load())
+
+			// the stale value is served while the entry is reloaded once
+			clock.Advance(55 * time.Second)
+			assert.Equal(t, 1, m.Get("a"))
+			assert.Equal(t, 1, m.Get("a"))
+			assert.Equal(t, 1, m.Get("fail"))
+			assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+			close(release)
+
+			// the new value gets the TTL the entry had
+			assert.Eventually(t, func() bool { return m.Get("a") == 2 }, time.Second, time.Millisecond)
+			ttl, _ := m.GetTTL("a")
+			assert.Equal(t, time.Minute, ttl)
+
+			// a failed reload leaves the entry as is, to be reloaded on a later read
+			assert.Eventually(t, func() bool {
+				return m.Get("fail") == 1 && calls.Load() >= 3
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestWithRefreshAhead_Races(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	var calls atomic.Int64
+	loader := func(key string) (int, error) {
+		if calls.Add(1) == 1 && key == "panic" {
+			panic("loader failed")
+		}
+		<-release
+		return 100, nil
+	}
+	m := NewSafeMap(withClock[string, int](clock.Now), WithRefreshAhead(10*time.Second, loader))
+	defer m.Close()
+
+	// a write made while the entry is reloaded is not overwritten by the reloaded value
+	m.SetWithTTL("a", 1, time.Minute)
+	clock.Advance(55 * time.Second)
+	m.Get("a")
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	m.Set("a", 42)
+	close(release)
+	m.SetWithTTL("b", 1, time.Minute)
+	clock.Advance(55 * time.Second)
+	m.Get("b")
+	assert.Eventually(t, func() bool { return m.Get("b") == 100 }, time.Second, time.Millisecond)
+	assert.Equal(t, 42, m.Get("a"))
+
+	// a panicking loader fails the reload, the entry is reloaded on a later read
+	calls.Store(0)
+	m.SetWithTTL("panic", 1, time.Minute)
+	clock.Advance(55 * time.Second)
+	assert.Equal(t, 1, m.Get("panic"))
+	assert.Eventually(t, func() bool {
+		return m.Get("panic") == 100
+	}, time.Second, time.Millisecond)
+}
+
+func TestWithNegativeCache(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeMap(withClock[string, int](clock.Now), WithNegativeCache[string, int](5*time.Second))
+	defer m.Close()
+
+	var calls atomic.Int64
+	errMissing := errors.New("missing")
+	loader := func(key string) (int, error) {
+		calls.Add(1)
+		return 0, errMissing
+	}
+
+	for range 3 {
+		_, err := m.GetOrCompute("a", loader)
+		assert.ErrorIs(t, err, errMissing)
+	}
+	assert.Equal(t, int64(1), calls.Load())
+	assert.False(t, m.Exist("a"), "the error is not stored as a value")
+
+	// the error is forgotten after the duration
+	clock.Advance(5 * time.Second)
+	_, err := m.GetOrCompute("a", loader)
+	assert.ErrorIs(t, err, errMissing)
+	assert.Equal(t, int64(2), calls.Load())
+
+	// a value set in the meantime is returned right away
+	m.Set("a", 1)
+	val, err := m.GetOrCompute("a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+	assert.Equal(t, int64(2), calls.Load())
+
+	// the expired errors are dropped as new ones are cached
+	for i := range 100 {
+		clock.Advance(time.Second)
+		_, _ = m.GetOrCompute(strconv.Itoa(i), loader)
+	}
+	assert.Less(t, len(m.flights.failures), 70)
+}
@@ -0,0 +1,41 @@
+package safemap
+
+import "iter"
+
+// Map is the method set shared by SafeMap and ShardedSafeMap, to depend on in place of either of them.
+// The safemaptest package provides a deterministic, non-concurrent implementation to substitute in unit tests.
+// example
+//
+//	type Service struct {
+//		sessions safemap.Map[string, Session]
+//	}
+//
+//	svc := &Service{sessions: safemap.NewSafeMap[string, Session]()}
+type Map[k comparable, v any] interface {
+	Set(key k, val v)
+	Get(key k) v
+	Lookup(key k) (val v, ok bool)
+	Delete(key k)
+	Exist(key k) bool
+	GetOrSet(key k, val v) (actual v, loaded bool)
+	GetAndDelete(key k) (val v, ok bool)
+	Swap(key k, val v) (previous v, loaded bool)
+	CompareAndSwap(key k, old, new v) (swapped bool)
+	CompareAndDelete(key k, old v) (deleted bool)
+	Update(key k, fn func(old v, exists bool) (new v, keep bool)) (val v, ok bool)
+	Upsert(key k, val v, merge func(existing, incoming v) v) v
+	SetIfAbsent(key k, val v) bool
+	SetIfPresent(key k, val v) bool
+	Length() int
+	GetMap() map[k]v
+	All() iter.Seq2[k, v]
+	Keys() iter.Seq[k]
+	Values() iter.Seq[v]
+	Clear() int
+	Close() error
+}
+
+var (
+	_ Map[string, int] = (*SafeMap[string, int])(nil)
+	_ Map[string, int] = (*ShardedSafeMap[string, int])(nil)
+)
@@ -0,0 +1,186 @@
+package safemap
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the buckets of the latency histograms, the last bucket is unbounded.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond,
+	4 * time.Microsecond,
+	16 * time.Microsecond,
+	64 * time.Microsecond,
+	256 * time.Microsecond,
+	time.Millisecond,
+	4 * time.Millisecond,
+	16 * time.Millisecond,
+	64 * time.Millisecond,
+	256 * time.Millisecond,
+	time.Second,
+}
+
+type (
+	// Collector gathers the metrics of a SafeMap, see SafeMap.Collector.
+	// It doesn't depend on any metrics library and is meant to be wrapped, for example by a prometheus.Collector.
+	Collector struct {
+		collect func() Metrics
+	}
+
+	// Metrics is a snapshot of the metrics of a SafeMap.
+	Metrics struct {
+		// Size is the number of entries.
+		Size int
+
+		// Stats holds the counters of the operations, such as the hits, misses and evictions.
+		Stats Stats
+
+		// Queue reports the load on the worker goroutine.
+		Queue QueueStats
+
+		// Latencies holds the histogram of the end-to-end latency of every kind of operation,
+		// keyed by operation name such as "get" or "set".
+		Latencies map[string]Histogram
+	}
+
+	// QueueStats reports the load on the worker goroutine of a SafeMap, see SafeMap.QueueStats.
+	QueueStats struct {
+		// Pending is the number of operations handed over to the worker and not taken yet,
+		// including the ones whose caller is still waiting for the worker or the queue to accept them.
+		Pending int
+
+		// Blocked is the cumulative time callers spent waiting for the worker or the queue to accept their operations.
+		Blocked time.Duration
+	}
+
+	// Histogram is a snapshot of the distribution of the latencies of an operation.
+	// Its buckets are cumulative, as expected by Prometheus.
+	Histogram struct {
+		// Bounds holds the upper bound of every bucket, and Counts the number of latencies lower than or equal to it.
+		Bounds []time.Duration
+		Counts []uint64
+
+		// Count is the total number of latencies, including the ones above the last bound, and Sum their sum.
+		Count uint64
+		Sum   time.Duration
+	}
+
+	// histogram records the distribution of the latencies of an operation.
+	histogram struct {
+		// counts holds the number of latencies per bucket, the last one counts the latencies above every bound.
+		counts [len(latencyBounds) + 1]atomic.Uint64
+		sum    atomic.Int64
+	}
+
+	// latencies records a histogram per operation, once a Collector was created.
+	latencies struct {
+		byOp sync.Map
+	}
+)
+
+// observe records a latency.
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// snapshot returns the cumulative distribution of the latencies.
+func (h *histogram) snapshot() Histogram {
+	snapshot := Histogram{
+		Bounds: slices.Clone(latencyBounds[:]),
+		Counts: make([]uint64, len(latencyBounds)),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		snapshot.Count += h.counts[i].Load()
+		if i < len(latencyBounds) {
+			snapshot.Counts[i] = snapshot.Count
+		}
+	}
+	return snapshot
+}
+
+// observe records the latency of an operation.
+func (l *latencies) observe(op string, d time.Duration) {
+	h, ok := l.byOp.Load(op)
+	if !ok {
+		h, _ = l.byOp.LoadOrStore(op, new(histogram))
+	}
+	h.(*histogram).observe(d)
+}
+
+// snapshot returns the distribution of the latencies of every operation.
+func (l *latencies) snapshot() map[string]Histogram {
+	snapshot := make(map[string]Histogram)
+	l.byOp.Range(func(op, h any) bool {
+		snapshot[op.(string)] = h.(*histogram).snapshot()
+		return true
+	})
+	return snapshot
+}
+
+// Collector returns a Collector gathering the size, the counters and the latency histograms of the SafeMap.
+// The latencies of the operations are only recorded from the first call to Collector, so they cost nothing before.
+// Latencies are measured end to end, from the call of a method until it returns, including the time spent waiting for the worker.
+// Like the counters, the metrics of a closed SafeMap remain available and report an empty map.
+// example
+//
+//	// Describe and Collect of a prometheus.Collector wrapping a SafeMap
+//	metrics := sessions.Collector().Collect()
+//	ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(metrics.Size))
+func (s *SafeMap[k, v]) Collector() *Collector {
+	// the collector references the state rather than the handle, so it doesn't keep the SafeMap from being collected
+	c := s.load()
+	c.latencies.CompareAndSwap(nil, new(latencies))
+	return &Collector{collect: func() Metrics {
+		return Metrics{
+			Size:      c.length(),
+			Stats:     c.stats.snapshot(),
+			Queue:     c.queueStats(),
+			Latencies: c.latencies.Load().snapshot(),
+		}
+	}}
+}
+
+// QueueStats reports the load on the worker goroutine: the operations waiting for it and the cumulative time callers
+// spent blocked handing operations over to it. A growing number of pending operations, or blocked time growing
+// as fast as the wall clock, tells that the worker became the bottleneck, before the latency of operations explodes.
+// With WithMutex there is no worker and both stay zero, so does Pending once the SafeMap is closed.
+// example
+//
+//	if q := m.QueueStats(); q.Pending > 1000 {
+//		log.Printf("safemap: %d pending operations, callers blocked %s", q.Pending, q.Blocked)
+//	}
+func (s *SafeMap[k, v]) QueueStats() QueueStats {
+	return s.load().queueStats()
+}
+
+// queueStats reads the load on the worker.
+func (c *core[k, v]) queueStats() QueueStats {
+	stats := QueueStats{Blocked: time.Duration(c.blocked.Load())}
+	select {
+	case <-c.done:
+		// the operations left in the queue are never taken
+	default:
+		stats.Pending = int(c.queued.Load())
+	}
+	return stats
+}
+
+// length returns the number of entries without recording the latency of the operation.
+func (c *core[k, v]) length() int {
+	reply, _ := c.deliver(context.Background(), operation[k, v]{op: "getLen"})
+	return reply.n
+}
+
+// Collect returns a snapshot of the metrics of the SafeMap.
+func (c *Collector) Collect() Metrics {
+	return c.collect()
+}
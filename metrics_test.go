@@ -0,0 +1,75 @@
+package safemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Collector(t *testing.T) {
+	m := NewSafeMap(WithMaxEntries[string, int](2))
+	// latencies are only recorded once a collector exists
+	m.Set("a", 1)
+
+	collector := m.Collector()
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Get("c")
+	m.Get("z")
+
+	metrics := collector.Collect()
+	assert.Equal(t, 2, metrics.Size)
+	assert.Equal(t, Stats{Gets: 2, Hits: 1, Misses: 1, Sets: 3, Evictions: 1}, metrics.Stats)
+	assert.Zero(t, metrics.Queue.Pending)
+	// collecting the metrics isn't recorded
+	assert.Len(t, metrics.Latencies, 2)
+	assert.Equal(t, uint64(2), metrics.Latencies["set"].Count)
+	assert.Equal(t, uint64(2), metrics.Latencies["get"].Count)
+	assert.Equal(t, metrics.Latencies, m.Collector().Collect().Latencies)
+
+	assert.NoError(t, m.Close())
+	assert.Zero(t, collector.Collect().Size)
+}
+
+func TestSafeMap_QueueStats(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go m.Update("a", func(old int, exists bool) (int, bool) {
+		close(started)
+		<-release
+		return 1, true
+	})
+	<-started
+
+	// with the worker busy, the next operations wait to be accepted
+	for range 3 {
+		go m.Set("b", 2)
+	}
+	go m.SetAsync("c", 3)
+	assert.Eventually(t, func() bool { return m.QueueStats().Pending == 4 }, time.Second, time.Millisecond)
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	// the time blocked is recorded once the callers return, after the worker took their operations
+	assert.Eventually(t, func() bool { return m.QueueStats().Blocked > 40*time.Millisecond }, time.Second, time.Millisecond)
+	assert.Zero(t, m.QueueStats().Pending)
+
+	assert.Zero(t, NewSafeMap(WithMutex[string, int]()).QueueStats())
+}
+
+func TestHistogram(t *testing.T) {
+	var h histogram
+	h.observe(0)
+	h.observe(time.Microsecond)
+	h.observe(3 * time.Microsecond)
+	h.observe(time.Hour)
+
+	snapshot := h.snapshot()
+	assert.Equal(t, latencyBounds[:], snapshot.Bounds)
+	assert.Equal(t, []uint64{2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}, snapshot.Counts)
+	assert.Equal(t, uint64(4), snapshot.Count)
+	assert.Equal(t, time.Hour+4*time.Microsecond, snapshot.Sum)
+}
@@ -0,0 +1,107 @@
+package safemap
+
+import (
+	"iter"
+	"slices"
+)
+
+// SafeMultiMap is a thread-safe map holding several values per key, backed by a SafeMap of slices.
+// The slices are only modified inside the worker goroutine, so appending and removing values is atomic,
+// and the methods return copies of them. The zero value is an empty map ready to use.
+// A SafeMultiMap must not be copied after first use.
+// example
+//
+//	var members SafeMultiMap[string, string]
+//	members.Append("admins", "alice", "bob")
+//	members.RemoveValue("admins", "bob")
+//	fmt.Println(members.GetAll("admins")) // [alice]
+type SafeMultiMap[k comparable, v comparable] struct {
+	// m holds a non-empty slice per key. A stored slice is never modified below its length:
+	// appending may fill its spare capacity, and removing a value builds a new slice.
+	m SafeMap[k, []v]
+}
+
+// NewSafeMultiMap creates an empty SafeMultiMap with the given options.
+func NewSafeMultiMap[k comparable, v comparable](opts ...Option[k, []v]) *SafeMultiMap[k, v] {
+	m := &SafeMultiMap[k, v]{}
+	m.m.configure(opts)
+	return m
+}
+
+// Append adds the values to the end of the values of the given key, in a single operation,
+// and returns the number of values the key holds afterwards.
+func (m *SafeMultiMap[k, v]) Append(key k, vals ...v) int {
+	if len(vals) == 0 {
+		return m.Count(key)
+	}
+	all, _ := m.m.Update(key, func(old []v, _ bool) ([]v, bool) {
+		return append(old, vals...), true
+	})
+	return len(all)
+}
+
+// RemoveValue removes the first occurrence of val from the values of the given key and reports whether it was found.
+// The key is deleted once it holds no value.
+func (m *SafeMultiMap[k, v]) RemoveValue(key k, val v) bool {
+	removed := false
+	m.m.Update(key, func(old []v, exists bool) ([]v, bool) {
+		i := slices.Index(old, val)
+		if i < 0 {
+			return old, exists
+		}
+		removed = true
+		if len(old) == 1 {
+			return nil, false
+		}
+		return slices.Concat(old[:i], old[i+1:]), true
+	})
+	return removed
+}
+
+// GetAll returns a copy of the values of the given key in the order they were appended, or nil if the key is absent.
+func (m *SafeMultiMap[k, v]) GetAll(key k) []v {
+	return slices.Clone(m.m.Get(key))
+}
+
+// Count returns the number of values of the given key.
+func (m *SafeMultiMap[k, v]) Count(key k) int {
+	return len(m.m.Get(key))
+}
+
+// Delete removes the given key and all its values, and returns the values it held.
+func (m *SafeMultiMap[k, v]) Delete(key k) []v {
+	vals, _ := m.m.GetAndDelete(key)
+	return slices.Clone(vals)
+}
+
+// Exist reports whether the given key holds at least one value.
+func (m *SafeMultiMap[k, v]) Exist(key k) bool {
+	return m.m.Exist(key)
+}
+
+// Len returns the number of keys holding at least one value.
+func (m *SafeMultiMap[k, v]) Len() int {
+	return m.m.Length()
+}
+
+// All returns an iterator over the keys and copies of their values, at the time All is called.
+func (m *SafeMultiMap[k, v]) All() iter.Seq2[k, []v] {
+	snap := m.m.Snapshot()
+	return func(yield func(k, []v) bool) {
+		for key, vals := range snap.All() {
+			if !yield(key, slices.Clone(vals)) {
+				return
+			}
+		}
+	}
+}
+
+// Clear removes all the keys and returns the number of keys removed.
+func (m *SafeMultiMap[k, v]) Clear() int {
+	return m.m.Clear()
+}
+
+// Close stops the worker goroutine of the SafeMultiMap, see SafeMap.Close.
+func (m *SafeMultiMap[k, v]) Close() error {
+	return m.m.Close()
+}
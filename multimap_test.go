@@ -0,0 +1,61 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMultiMap(t *testing.T) {
+	var m SafeMultiMap[string, int]
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				m.Append("all", w*100+i)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 800, m.Count("all"))
+	assert.Len(t, m.GetAll("all"), 800)
+
+	assert.Equal(t, 3, m.Append("k", 1, 2, 1))
+	assert.True(t, m.RemoveValue("k", 1))
+	assert.Equal(t, []int{2, 1}, m.GetAll("k"), "the first occurrence is removed")
+	assert.False(t, m.RemoveValue("k", 3))
+	assert.False(t, m.RemoveValue("absent", 3))
+	assert.False(t, m.Exist("absent"))
+
+	assert.True(t, m.RemoveValue("k", 2))
+	assert.True(t, m.RemoveValue("k", 1))
+	assert.False(t, m.Exist("k"), "a key without values is deleted")
+	assert.Nil(t, m.GetAll("k"))
+	assert.Equal(t, 1, m.Len())
+
+	assert.Len(t, m.Delete("all"), 800)
+	assert.Zero(t, m.Len())
+}
+
+func TestSafeMultiMap_Copies(t *testing.T) {
+	m := NewSafeMultiMap[string, int]()
+	defer m.Close()
+
+	m.Append("k", 1, 2, 3)
+	vals := m.GetAll("k")
+	vals[0] = 10
+	vals = append(vals, 4)
+	assert.Equal(t, []int{1, 2, 3}, m.GetAll("k"), "the values returned are copies")
+
+	for _, vals := range m.All() {
+		vals[0] = 10
+	}
+	m.RemoveValue("k", 2)
+	m.Append("k", 5)
+	assert.Equal(t, []int{1, 3, 5}, m.GetAll("k"))
+}
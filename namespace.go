@@ -0,0 +1,241 @@
+package safemap
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// SafeNamespaceMap is a thread-safe two-level map, grouping its entries by namespace.
+// All the namespaces share a single worker goroutine, and the operations on a whole namespace,
+// such as Namespace, SetManyIn or DeleteNamespace, are atomic. The options apply to every entry,
+// with the keys as NamespacedKey. The zero value is an empty SafeNamespaceMap ready to use,
+// NewSafeNamespaceMap is only needed to pass options. A SafeNamespaceMap must not be copied after first use.
+// example
+//
+//	settings := NewSafeNamespaceMap[string, string, string]()
+//	settings.SetIn("tenant-1", "theme", "dark")
+//	for key, val := range settings.Namespace("tenant-1") {
+//		fmt.Println(key, val)
+//	}
+type SafeNamespaceMap[n comparable, k comparable, v any] struct {
+	m SafeMap[NamespacedKey[n, k], v]
+
+	// once lazily creates the SafeMap of a zero value SafeNamespaceMap over a namespaceBackend.
+	once sync.Once
+}
+
+// NamespacedKey is the key of an entry of a SafeNamespaceMap, seen by its options, hooks and events.
+type NamespacedKey[n comparable, k comparable] struct {
+	Namespace n
+	Key       k
+}
+
+type (
+	// namespacedStore is implemented by namespaceBackend, it lets the worker visit a namespace without knowing its type.
+	// A namespace is designated by any key within it.
+	namespacedStore[k comparable, v any] interface {
+		// visitIn calls visit for each entry in the namespace of key.
+		visitIn(key k, visit func(key k, val v) bool)
+		// keysIn returns the keys in the namespace of key.
+		keysIn(key k) []k
+		// visitNamespaces calls visit with a key of each namespace.
+		visitNamespaces(visit func(key k, val v) bool)
+	}
+
+	// namespaceBackend is the Backend of a SafeNamespaceMap, a map of the entries of each namespace.
+	// A namespace without entries is removed.
+	namespaceBackend[n comparable, k comparable, v any] struct {
+		spaces map[n]map[k]v
+		len    int
+	}
+)
+
+// NewSafeNamespaceMap creates a SafeNamespaceMap with the given options.
+// It panics if combined with WithBackend, WithCopyOnWrite, WithSnapshotReads or WithInsertionOrder.
+// example
+//
+//	sessions := NewSafeNamespaceMap(WithDefaultTTL[NamespacedKey[string, string], Session](time.Hour))
+func NewSafeNamespaceMap[n comparable, k comparable, v any](opts ...Option[NamespacedKey[n, k], v]) *SafeNamespaceMap[n, k, v] {
+	m := &SafeNamespaceMap[n, k, v]{}
+	m.configure(opts)
+	return m
+}
+
+// configure creates the SafeMap of the SafeNamespaceMap over a namespaceBackend, with the given options.
+func (m *SafeNamespaceMap[n, k, v]) configure(opts []Option[NamespacedKey[n, k], v]) {
+	m.m.configure(append(slices.Clip(opts), func(c *config[NamespacedKey[n, k], v]) {
+		c.newStore = func(int) Backend[NamespacedKey[n, k], v] {
+			return &namespaceBackend[n, k, v]{spaces: make(map[n]map[k]v)}
+		}
+		c.kind = "SafeNamespaceMap"
+	}))
+}
+
+// load returns the SafeMap of the SafeNamespaceMap, creating it with the default configuration
+// on first use of a zero value SafeNamespaceMap.
+func (m *SafeNamespaceMap[n, k, v]) load() *SafeMap[NamespacedKey[n, k], v] {
+	m.once.Do(func() {
+		if m.m.core == nil {
+			m.configure(nil)
+		}
+	})
+	return &m.m
+}
+
+// SetIn sets the value for the given key in the given namespace.
+func (m *SafeNamespaceMap[n, k, v]) SetIn(ns n, key k, val v) {
+	m.load().Set(NamespacedKey[n, k]{ns, key}, val)
+}
+
+// SetManyIn sets all the entries of items in the given namespace in a single operation.
+func (m *SafeNamespaceMap[n, k, v]) SetManyIn(ns n, items map[k]v) {
+	namespaced := make(map[NamespacedKey[n, k]]v, len(items))
+	for key, val := range items {
+		namespaced[NamespacedKey[n, k]{ns, key}] = val
+	}
+	m.load().SetMany(namespaced)
+}
+
+// GetIn returns the value for the given key in the given namespace, or the zero value if it is not present.
+func (m *SafeNamespaceMap[n, k, v]) GetIn(ns n, key k) v {
+	return m.load().Get(NamespacedKey[n, k]{ns, key})
+}
+
+// LookupIn returns the value for the given key in the given namespace and whether it is present.
+func (m *SafeNamespaceMap[n, k, v]) LookupIn(ns n, key k) (val v, ok bool) {
+	return m.load().Lookup(NamespacedKey[n, k]{ns, key})
+}
+
+// DeleteIn removes the given key from the given namespace.
+func (m *SafeNamespaceMap[n, k, v]) DeleteIn(ns n, key k) {
+	m.load().Delete(NamespacedKey[n, k]{ns, key})
+}
+
+// Namespace returns a copy of the entries of the given namespace, taken in a single operation.
+// It returns an empty map if the namespace holds no entry.
+func (m *SafeNamespaceMap[n, k, v]) Namespace(ns n) map[k]v {
+	items := make(map[k]v)
+	m.load().send(operation[NamespacedKey[n, k], v]{
+		op:  "namespace",
+		key: NamespacedKey[n, k]{Namespace: ns},
+		visit: func(key NamespacedKey[n, k], val v) bool {
+			items[key.Key] = val
+			return true
+		},
+	})
+	return items
+}
+
+// DeleteNamespace removes all the entries of the given namespace in a single operation
+// and returns the number of entries removed.
+func (m *SafeNamespaceMap[n, k, v]) DeleteNamespace(ns n) int {
+	return m.load().send(operation[NamespacedKey[n, k], v]{
+		op:  "deleteNamespace",
+		key: NamespacedKey[n, k]{Namespace: ns},
+	}).n
+}
+
+// Namespaces returns the namespaces holding at least one entry, in an unspecified order.
+func (m *SafeNamespaceMap[n, k, v]) Namespaces() []n {
+	var spaces []n
+	m.load().send(operation[NamespacedKey[n, k], v]{
+		op: "namespaces",
+		visit: func(key NamespacedKey[n, k], _ v) bool {
+			spaces = append(spaces, key.Namespace)
+			return true
+		},
+	})
+	return spaces
+}
+
+// Length returns the number of entries across all namespaces.
+func (m *SafeNamespaceMap[n, k, v]) Length() int {
+	return m.load().Length()
+}
+
+// All returns an iterator over the entries of all namespaces at the time All is called.
+func (m *SafeNamespaceMap[n, k, v]) All() iter.Seq2[NamespacedKey[n, k], v] {
+	return m.load().All()
+}
+
+// Clear removes the entries of all namespaces and returns the number of entries removed.
+func (m *SafeNamespaceMap[n, k, v]) Clear() int {
+	return m.load().Clear()
+}
+
+// Close stops the worker goroutine of the SafeNamespaceMap, see SafeMap.Close.
+func (m *SafeNamespaceMap[n, k, v]) Close() error {
+	return m.load().Close()
+}
+
+func (b *namespaceBackend[n, k, v]) Get(key NamespacedKey[n, k]) (v, bool) {
+	val, ok := b.spaces[key.Namespace][key.Key]
+	return val, ok
+}
+
+func (b *namespaceBackend[n, k, v]) Set(key NamespacedKey[n, k], val v) {
+	space, ok := b.spaces[key.Namespace]
+	if !ok {
+		space = make(map[k]v)
+		b.spaces[key.Namespace] = space
+	}
+	if _, ok := space[key.Key]; !ok {
+		b.len++
+	}
+	space[key.Key] = val
+}
+
+func (b *namespaceBackend[n, k, v]) Delete(key NamespacedKey[n, k]) {
+	space := b.spaces[key.Namespace]
+	if _, ok := space[key.Key]; !ok {
+		return
+	}
+	b.len--
+	delete(space, key.Key)
+	if len(space) == 0 {
+		delete(b.spaces, key.Namespace)
+	}
+}
+
+func (b *namespaceBackend[n, k, v]) Len() int {
+	return b.len
+}
+
+func (b *namespaceBackend[n, k, v]) All() iter.Seq2[NamespacedKey[n, k], v] {
+	return func(yield func(NamespacedKey[n, k], v) bool) {
+		for ns, space := range b.spaces {
+			for key, val := range space {
+				if !yield(NamespacedKey[n, k]{ns, key}, val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *namespaceBackend[n, k, v]) visitIn(key NamespacedKey[n, k], visit func(key NamespacedKey[n, k], val v) bool) {
+	for inner, val := range b.spaces[key.Namespace] {
+		if !visit(NamespacedKey[n, k]{key.Namespace, inner}, val) {
+			return
+		}
+	}
+}
+
+func (b *namespaceBackend[n, k, v]) keysIn(key NamespacedKey[n, k]) []NamespacedKey[n, k] {
+	keys := make([]NamespacedKey[n, k], 0, len(b.spaces[key.Namespace]))
+	for inner := range maps.Keys(b.spaces[key.Namespace]) {
+		keys = append(keys, NamespacedKey[n, k]{key.Namespace, inner})
+	}
+	return keys
+}
+
+func (b *namespaceBackend[n, k, v]) visitNamespaces(visit func(key NamespacedKey[n, k], val v) bool) {
+	var zero v
+	for ns := range b.spaces {
+		if !visit(NamespacedKey[n, k]{Namespace: ns}, zero) {
+			return
+		}
+	}
+}
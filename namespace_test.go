@@ -0,0 +1,70 @@
+package safemap
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeNamespaceMap(t *testing.T) {
+	m := NewSafeNamespaceMap[string, string, int]()
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for _, ns := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				m.SetIn(ns, fmt.Sprint(i), i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 300, m.Length())
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, m.Namespaces())
+	assert.Equal(t, 42, m.GetIn("b", "42"))
+	_, ok := m.LookupIn("d", "42")
+	assert.False(t, ok)
+
+	m.DeleteIn("a", "0")
+	assert.Len(t, m.Namespace("a"), 99)
+	assert.Len(t, m.Namespace("b"), 100)
+	assert.Empty(t, m.Namespace("d"))
+
+	assert.Equal(t, 100, m.DeleteNamespace("b"))
+	assert.Zero(t, m.DeleteNamespace("b"))
+	assert.ElementsMatch(t, []string{"a", "c"}, m.Namespaces())
+	assert.Equal(t, 199, m.Length())
+
+	m.SetManyIn("d", map[string]int{"x": 1, "y": 2})
+	assert.Equal(t, map[string]int{"x": 1, "y": 2}, m.Namespace("d"))
+	for key, val := range m.All() {
+		assert.Equal(t, val, m.GetIn(key.Namespace, key.Key))
+	}
+}
+
+func TestSafeNamespaceMap_Options(t *testing.T) {
+	deleted := make(chan NamespacedKey[string, int], 1)
+	m := NewSafeNamespaceMap(WithOnDelete[NamespacedKey[string, int], string](func(key NamespacedKey[string, int]) {
+		deleted <- key
+	}))
+	defer m.Close()
+
+	m.SetIn("ns", 1, "a")
+	m.DeleteNamespace("ns")
+	assert.Equal(t, NamespacedKey[string, int]{"ns", 1}, <-deleted, "the callbacks see the removal of every entry")
+}
+
+func TestSafeNamespaceMap_ZeroValue(t *testing.T) {
+	var settings SafeNamespaceMap[string, string, int]
+	settings.SetIn("tenant-1", "a", 1)
+	settings.SetIn("tenant-2", "a", 2)
+	assert.Equal(t, 1, settings.GetIn("tenant-1", "a"))
+	assert.Equal(t, map[string]int{"a": 2}, settings.Namespace("tenant-2"))
+	assert.Equal(t, 1, settings.DeleteNamespace("tenant-1"))
+	assert.NoError(t, settings.Close())
+}
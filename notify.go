@@ -0,0 +1,89 @@
+package safemap
+
+import "sync"
+
+type (
+	// notification is a change of an entry waiting to be passed to the callbacks of WithOnSet, WithOnDelete,
+	// WithOnExpire and WithOnEvict. The reason is zero for an entry written, and tells why it was removed otherwise.
+	notification[k comparable, v any] struct {
+		key    k
+		value  v
+		reason EvictionReason
+	}
+
+	// notifier queues the changes for the goroutine running the callbacks, or forwarding them to a watcher.
+	// The queue is unbounded, so the worker never waits for the receiver.
+	notifier[k comparable, v any] struct {
+		mu    sync.Mutex
+		queue []notification[k, v]
+
+		// wake signals the callback goroutine that the queue is not empty.
+		wake chan struct{}
+	}
+)
+
+// push queues a change for the callbacks.
+func (n *notifier[k, v]) push(change notification[k, v]) {
+	n.mu.Lock()
+	n.queue = append(n.queue, change)
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns every queued change.
+func (n *notifier[k, v]) take() []notification[k, v] {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	queue := n.queue
+	n.queue = nil
+	return queue
+}
+
+// notifies reports whether the callbacks are interested in a change, a zero reason standing for a write.
+func (c *core[k, v]) notifies(reason EvictionReason) bool {
+	if c.notifier == nil {
+		return false
+	}
+	if reason == 0 {
+		return c.cfg.onSet != nil
+	}
+	return c.cfg.onDelete != nil || c.cfg.onEvict != nil || reason == EvictionExpired && c.cfg.onExpire != nil
+}
+
+// notify passes the changes to the callbacks as they are queued, until the SafeMap is closed.
+// The changes made before Close are still passed to the callbacks.
+func (c *core[k, v]) notify() {
+	for {
+		select {
+		case <-c.notifier.wake:
+		case <-c.done:
+			c.report(c.notifier.take())
+			return
+		}
+		c.report(c.notifier.take())
+	}
+}
+
+// report passes the changes to the callbacks, in the order they were made.
+func (c *core[k, v]) report(changes []notification[k, v]) {
+	for _, n := range changes {
+		if n.reason == 0 {
+			c.cfg.onSet(n.key, n.value)
+			continue
+		}
+
+		if n.reason == EvictionExpired && c.cfg.onExpire != nil {
+			c.cfg.onExpire(n.key, n.value)
+		}
+		if c.cfg.onEvict != nil {
+			c.cfg.onEvict(n.key, n.value, n.reason)
+		}
+		if c.cfg.onDelete != nil {
+			c.cfg.onDelete(n.key)
+		}
+	}
+}
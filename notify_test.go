@@ -0,0 +1,54 @@
+package safemap
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithOnSetOnDelete(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				changes []string
+			)
+			record := func(change string) {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, change)
+			}
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts,
+				withClock[string, int](clock.Now),
+				WithMaxEntries[string, int](2),
+				WithOnSet(func(key string, val int) { record(fmt.Sprint("set ", key, "=", val)) }),
+				WithOnDelete[string, int](func(key string) { record("delete " + key) }),
+			)...)
+
+			m.Set("a", 1)
+			m.Update("a", func(old int, exists bool) (int, bool) { return old + 1, true })
+			m.CompareAndSwap("a", 1, 3)
+			m.SetWithTTL("b", 2, time.Minute)
+			m.Set("c", 3)
+			clock.Advance(time.Minute)
+			m.Delete("c")
+			m.Delete("z")
+			assert.NoError(t, m.Close())
+
+			// the changes made before Close are reported
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(changes) == 7
+			}, time.Second, time.Millisecond)
+			assert.Equal(t, []string{"set a=1", "set a=2", "set b=2", "set c=3", "delete a", "delete b", "delete c"}, changes)
+		})
+	}
+}
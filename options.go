@@ -0,0 +1,489 @@
+package safemap
+
+import (
+	"io"
+	"time"
+)
+
+type (
+	// Option configures a SafeMap created with NewSafeMap.
+	// Options are applied in order, when an option is repeated the last one wins.
+	Option[k comparable, v any] func(*config[k, v])
+
+	// config holds the settings collected from the options passed to NewSafeMap.
+	config[k comparable, v any] struct {
+		readConcurrency int
+		mutex           bool
+		backend         Backend[k, v]
+		queueSize       int
+		snapshotReads   bool
+		copyOnWrite     bool
+		capacity        int
+		maxEntries      int
+		policy          func() EvictionPolicy[k]
+		hooks           Hooks
+		slowOpThreshold time.Duration
+		onSlowOp        func(op string, elapsed time.Duration)
+		keyHashing      bool
+		maxBytes        int
+		sizeOf          func(key k, val v) int
+		defaultTTL      time.Duration
+		cleanupInterval time.Duration
+		onExpire        func(key k, val v)
+		onEvict         func(key k, val v, reason EvictionReason)
+		onSet           func(key k, val v)
+		onDelete        func(key k)
+		sliding         bool
+
+		// snapshotPath is the file the entries are saved to every snapshotInterval, see WithAutoSnapshot.
+		snapshotPath     string
+		snapshotInterval time.Duration
+
+		// wal receives the records of every mutation, see WithWAL.
+		wal io.Writer
+
+		// keyCodec and valueCodec encode the entries for MarshalBinary and WithWAL, the default codecs are used when nil.
+		keyCodec   Codec[k]
+		valueCodec Codec[v]
+
+		// defaultValue computes the value Get returns for a missing key, see WithDefault.
+		defaultValue func(key k) v
+
+		// negativeTTL is how long GetOrCompute returns the error of a failed load without loading again.
+		negativeTTL time.Duration
+
+		// refreshAhead is the remaining TTL below which a read reloads the entry with refreshLoader.
+		refreshAhead  time.Duration
+		refreshLoader func(key k) (v, error)
+
+		// insertionOrder stores the entries in an orderedBackend, see WithInsertionOrder.
+		insertionOrder bool
+
+		// newStore creates the Backend of a specialised map such as SafeSortedMap, pre-sized for capacity entries,
+		// kind names that map in the panics of incompatible options.
+		newStore func(capacity int) Backend[k, v]
+		kind     string
+
+		// subscriptionBuffer is the buffer of every Subscribe channel, defaultSubscriptionBuffer when not positive.
+		subscriptionBuffer int
+
+		// clock replaces time.Now, it lets tests control the expiration of entries.
+		clock func() time.Time
+	}
+)
+
+// WithCapacity pre-sizes the internal map to hold n entries, see NewSafeMapWithCapacity.
+// example
+//
+//	m := NewSafeMap(WithCapacity[string, int](1_000_000), WithQueueSize[string, int](64))
+func WithCapacity[k comparable, v any](n int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.capacity = n
+	}
+}
+
+// WithReadConcurrency lets up to n read operations run in parallel.
+// Get, Exist, Length, GetMap and the iterators are then served under a read lock
+// instead of being funneled through the worker goroutine, while writes remain serialized by the worker.
+// A value of n lower than 2 keeps the default behaviour where every operation goes through the worker.
+// example
+//
+//	m := NewSafeMap[string, int](WithReadConcurrency[string, int](8))
+func WithReadConcurrency[k comparable, v any](n int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.readConcurrency = n
+	}
+}
+
+// WithMutex makes the SafeMap apply every operation in the calling goroutine under an internal RWMutex,
+// instead of sending it to a worker goroutine. It trades the actor model for throughput while keeping the same method set,
+// so call sites don't change. Read operations run in parallel under the read lock.
+// With WithMutex, functions passed to the SafeMap such as the Update callback hold the lock while they run.
+// example
+//
+//	m := NewSafeMap(WithMutex[string, int]())
+func WithMutex[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.mutex = true
+	}
+}
+
+// WithBackend makes the SafeMap store its entries in b instead of a plain Go map.
+// The SafeMap keeps providing the concurrency, the atomic operations and the iterators on top of b,
+// for example to plug in a bounded or persistent store. The backend must not be used directly while the SafeMap is in use.
+// example
+//
+//	m := NewSafeMap(WithBackend[string, int](myStore))
+func WithBackend[k comparable, v any](b Backend[k, v]) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.backend = b
+	}
+}
+
+// WithQueueSize buffers up to n operations in front of the worker goroutine.
+// Callers then hand their operation over without waiting for the worker to be ready to receive it,
+// which absorbs bursts of writers, while each caller still waits for the reply of its own operation.
+// Operations are applied in the order they are queued. The option has no effect with WithMutex.
+// With a queue, TryGet and TrySet are accepted while the queue has room and then wait for the operations queued before them.
+// example
+//
+//	m := NewSafeMap(WithQueueSize[string, int](128))
+func WithQueueSize[k comparable, v any](n int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.queueSize = n
+	}
+}
+
+// WithSnapshotReads serves read operations from an immutable snapshot of the entries, published atomically by the worker
+// after every batch of writes. Reads then never wait for the worker or a lock, at the cost of copying the whole map
+// once per batch of writes, which suits read-heavy workloads. A write is visible to every read started after it returned.
+// example
+//
+//	m := NewSafeMap(WithSnapshotReads[string, int]())
+func WithSnapshotReads[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.snapshotReads = true
+	}
+}
+
+// WithCopyOnWrite makes GetMap, Keys, Values and All return the current version of the entries instead of a copy.
+// The version handed out is never modified, the first write after it copies the entries into a new version.
+// Snapshots are then taken in constant time, and only the first write following a snapshot pays for the copy.
+// Maps returned by GetMap must not be modified. It can't be combined with WithBackend.
+// example
+//
+//	m := NewSafeMap(WithCopyOnWrite[string, int]())
+func WithCopyOnWrite[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.copyOnWrite = true
+	}
+}
+
+// WithInsertionOrder keeps track of the order the keys were inserted in: Keys, Values, All, Range and Snapshot
+// iterate in that order, Oldest and Newest return the first and last entries, and Pop removes the oldest entry.
+// Setting the value of a present key keeps its position, deleting it forgets it.
+// The entries set in one operation, such as with SetMany, are inserted in an unspecified order.
+// It can't be combined with WithBackend, WithCopyOnWrite or WithSnapshotReads, nor used with a ShardedSafeMap.
+// example
+//
+//	m := NewSafeMap(WithInsertionOrder[string, int]())
+func WithInsertionOrder[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.insertionOrder = true
+	}
+}
+
+// WithMaxEntries bounds the SafeMap to n entries. Once a write makes it exceed n entries,
+// the least recently used entries are evicted until it holds n entries again.
+// Writing an entry and reading its value with Get, Lookup or GetMany count as a use.
+// Bounding the map makes every read update the order of use, so reads are always applied by the worker,
+// or under the write lock with WithMutex. A value of n lower than or equal to zero keeps the SafeMap unbounded.
+// example
+//
+//	cache := NewSafeMap(WithMaxEntries[string, []byte](10_000))
+func WithMaxEntries[k comparable, v any](n int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the approximate memory used by the entries of the SafeMap to n bytes,
+// as estimated by sizeOf for every entry written. Once a write makes the total exceed n,
+// entries are evicted as with WithMaxEntries until it fits again. An entry larger than n on its own is not stored.
+// sizeOf is called by the worker every time an entry is written, so it must be fast and must not use the SafeMap.
+// It can be combined with WithMaxEntries and WithLFU. A value of n lower than or equal to zero disables the bound.
+// example
+//
+//	cache := NewSafeMap(WithMaxBytes(64<<20, func(key string, val []byte) int { return len(key) + len(val) }))
+func WithMaxBytes[k comparable, v any](n int, sizeOf func(key k, val v) int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.maxBytes = n
+		c.sizeOf = sizeOf
+	}
+}
+
+// WithSizeEstimator makes the SafeMap maintain the approximate memory used by its entries, as estimated
+// by sizeOf for every entry written, reported by SizeBytes. Unlike WithMaxBytes it doesn't bound the SafeMap,
+// combined with it the function of the last of the two options is used.
+// sizeOf is called by the worker every time an entry is written, so it must be fast and must not use the SafeMap.
+// example
+//
+//	m := NewSafeMap(WithSizeEstimator(func(key string, val []byte) int { return len(key) + len(val) }))
+func WithSizeEstimator[k comparable, v any](sizeOf func(key k, val v) int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.sizeOf = sizeOf
+	}
+}
+
+// WithLFU makes a SafeMap bounded with WithMaxEntries or WithMaxBytes evict the least frequently used entries
+// instead of the least recently used ones. Among entries used as often, the least recently used one is evicted first.
+// It suits access patterns with hot keys, which a scan over many cold keys would otherwise evict.
+// example
+//
+//	cache := NewSafeMap(WithMaxEntries[string, []byte](10_000), WithLFU[string, []byte]())
+func WithLFU[k comparable, v any]() Option[k, v] {
+	return WithEvictionPolicy[k, v](NewLFU[k])
+}
+
+// WithEvictionPolicy makes a SafeMap bounded with WithMaxEntries or WithMaxBytes evict the entries chosen by
+// the policies returned by newPolicy, such as NewLRU, NewLFU or a custom implementation of EvictionPolicy.
+// newPolicy is called once per SafeMap, and again by Clone, which replays the keys of the clone into its new policy.
+// The option has no effect on an unbounded SafeMap.
+// example
+//
+//	m := NewSafeMap(WithMaxEntries[string, int](1000), WithEvictionPolicy[string, int](newARC))
+func WithEvictionPolicy[k comparable, v any](newPolicy func() EvictionPolicy[k]) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.policy = newPolicy
+	}
+}
+
+// WithDefault makes Get return fn(key) instead of the zero value when the key is missing, like a defaultdict.
+// The default value is not stored, so Lookup and Exist still report the key as missing, and fn is called again
+// on the next Get. fn runs in the calling goroutine, after the reply of the worker, so it may use the SafeMap.
+// Only Get and GetCtx use the default value, and with a ShardedSafeMap, its Get.
+// example
+//
+//	groups := NewSafeMap(WithDefault[string, []string](func(string) []string { return []string{"everyone"} }))
+//	groups.Get("alice") // [everyone]
+func WithDefault[k comparable, v any](fn func(key k) v) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.defaultValue = fn
+	}
+}
+
+// WithDefaultTTL makes every entry written without an explicit TTL expire once d has elapsed.
+// It applies to Set, Swap, SetMany, SetIfAbsent and GetOrSet, and to the keys inserted by Update and Upsert.
+// SetWithTTL still sets a specific TTL per entry, or no expiry at all with a ttl lower than or equal to zero.
+// example
+//
+//	sessions := NewSafeMap(WithDefaultTTL[string, Session](30 * time.Minute))
+func WithDefaultTTL[k comparable, v any](d time.Duration) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.defaultTTL = d
+	}
+}
+
+// WithCleanupInterval starts a janitor goroutine removing the expired entries every interval d.
+// Expired entries are never observed, but without a janitor they are only removed by the next operation on the SafeMap,
+// so an idle map keeps holding them. The janitor is stopped by Close. A value of d lower than or equal to zero disables it.
+// example
+//
+//	cache := NewSafeMap(WithDefaultTTL[string, []byte](time.Hour), WithCleanupInterval[string, []byte](time.Minute))
+func WithCleanupInterval[k comparable, v any](d time.Duration) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.cleanupInterval = d
+	}
+}
+
+// WithOnSet calls fn with every entry written, after the write, for example to mirror changes into an audit log.
+// It covers every method writing a value, such as Set, Update, CompareAndSwap or TransformValues.
+// Like the other callbacks, fn runs in a dedicated goroutine outside the worker, and receives the changes in the order
+// they were made, interleaved with the ones passed to the WithOnDelete callback.
+// example
+//
+//	m := NewSafeMap(WithOnSet(func(key string, val int) { audit.Printf("set %s=%d", key, val) }))
+func WithOnSet[k comparable, v any](fn func(key k, val v)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.onSet = fn
+	}
+}
+
+// WithOnDelete calls fn with the key of every entry removed from the SafeMap, after its removal.
+// Unlike WithOnEvict, it doesn't tell why the entry was removed: explicit deletes, evictions and expirations are all reported.
+// Entries dropped by Close are not reported. Like the other callbacks, fn runs in a dedicated goroutine outside the worker.
+// example
+//
+//	m := NewSafeMap(WithOnDelete[string, int](func(key string) { audit.Printf("delete %s", key) }))
+func WithOnDelete[k comparable, v any](fn func(key k)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.onDelete = fn
+	}
+}
+
+// WithOnEvict calls fn with every entry removed from the SafeMap, along with the reason of its removal:
+// EvictionCapacity for entries evicted by WithMaxEntries or WithMaxBytes, EvictionExpired for entries whose TTL elapsed,
+// and EvictionDeleted for entries removed explicitly, such as with Delete, Update or Clear.
+// Entries replaced by a new value and entries dropped by Close are not reported.
+// Like WithOnExpire, fn runs in a dedicated goroutine outside the worker and receives the entries in the order they were removed.
+// example
+//
+//	cache := NewSafeMap(WithMaxEntries[string, []byte](10_000), WithOnEvict(func(key string, val []byte, reason EvictionReason) {
+//		if reason == EvictionCapacity {
+//			disk.Write(key, val)
+//		}
+//	}))
+func WithOnEvict[k comparable, v any](fn func(key k, val v, reason EvictionReason)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.onEvict = fn
+	}
+}
+
+// WithSlidingExpiration renews the TTL of an entry every time its value is read with Get, Lookup or GetMany,
+// so an entry only expires once it went unread for its whole TTL, as an idle session would.
+// Exist, GetTTL and the methods visiting every entry don't renew the TTL.
+// example
+//
+//	sessions := NewSafeMap(WithDefaultTTL[string, Session](30*time.Minute), WithSlidingExpiration[string, Session]())
+func WithSlidingExpiration[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.sliding = true
+	}
+}
+
+// WithOnExpire calls fn with every entry removed because its TTL elapsed, for example to close a connection
+// stored as the value or to count expirations. fn runs in a dedicated goroutine, outside the worker,
+// so a slow callback never delays the operations on the SafeMap. Expired entries are passed to fn one at a time,
+// in the order they expired. Entries deleted or overwritten before they expire, and entries dropped by Close, are not reported.
+// example
+//
+//	conns := NewSafeMap(WithOnExpire(func(id string, conn net.Conn) { conn.Close() }))
+func WithOnExpire[k comparable, v any](fn func(key k, val v)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.onExpire = fn
+	}
+}
+
+// WithRefreshAhead reloads an entry in the background with loader when it is read while its remaining TTL is below threshold,
+// so hot keys stay warm instead of expiring. The stale value is still served until the new one is stored with the TTL
+// the entry had. A key is reloaded once at a time, and an entry written, deleted or expired in the meantime is not
+// overwritten by the reloaded value. If loader fails or panics the entry is left as is, to be reloaded on a later read.
+// example
+//
+//	prices := NewSafeMap(
+//		WithDefaultTTL[string, float64](time.Minute),
+//		WithRefreshAhead(10*time.Second, func(symbol string) (float64, error) {
+//			return quotes.Fetch(symbol)
+//		}),
+//	)
+func WithRefreshAhead[k comparable, v any](threshold time.Duration, loader func(key k) (v, error)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.refreshAhead = threshold
+		c.refreshLoader = loader
+	}
+}
+
+// WithNegativeCache makes GetOrCompute remember the error of a failed load for d, returning it for the key
+// without calling the loader again, so a key missing upstream doesn't cause a load on every request.
+// The error is never stored as a value: Get and the other methods still report the key as absent,
+// and a value set for the key in the meantime is returned by GetOrCompute right away.
+// example
+//
+//	users := NewSafeMap(WithNegativeCache[string, User](5 * time.Second))
+//	_, err := users.GetOrCompute(id, loadUser) // loads at most once every 5 seconds while it fails
+func WithNegativeCache[k comparable, v any](d time.Duration) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.negativeTTL = d
+	}
+}
+
+// WithHooks reports every operation of the SafeMap to h, which can attach a span or custom timing to it.
+// OnOpStart and OnOpEnd are called in the goroutine calling the method, around the whole operation
+// including the time spent waiting for the worker, or in the goroutine resolving the Future of GetFuture and ExistFuture.
+// SetAsync, DeleteAsync, TryGet and TrySet are not reported.
+// example
+//
+//	m := NewSafeMap(WithHooks[string, int](tracingHooks{tracer: otel.Tracer("sessions")}))
+func WithHooks[k comparable, v any](h Hooks) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.hooks = h
+	}
+}
+
+// WithKeyHashing makes the SafeMap pass a hash of the key of single key operations to the hooks of WithHooks,
+// as OpInfo.KeyHash. Hashing costs a little on every operation, so it is disabled by default.
+// example
+//
+//	m := NewSafeMap(WithHooks[string, int](hooks), WithKeyHashing[string, int]())
+func WithKeyHashing[k comparable, v any]() Option[k, v] {
+	return func(c *config[k, v]) {
+		c.keyHashing = true
+	}
+}
+
+// WithSlowOpThreshold calls fn with the name and the latency of every operation taking longer than d end to end,
+// including the time spent waiting for a saturated worker. fn is called in the goroutine calling the method,
+// once the operation completed, and for the same operations as the hooks of WithHooks.
+// example
+//
+//	m := NewSafeMap(WithSlowOpThreshold[string, int](10*time.Millisecond, func(op string, elapsed time.Duration) {
+//		log.Printf("safemap: slow %s took %s", op, elapsed)
+//	}))
+func WithSlowOpThreshold[k comparable, v any](d time.Duration, fn func(op string, elapsed time.Duration)) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.slowOpThreshold = d
+		c.onSlowOp = fn
+	}
+}
+
+// WithCodec sets the codecs encoding the keys and the values for MarshalBinary and UnmarshalBinary.
+// Either may be nil to keep the default codec, which supports strings, byte slices, booleans, integers, floats
+// and types implementing encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+// example
+//
+//	m := NewSafeMap(WithCodec[string, User](nil, userCodec{}))
+func WithCodec[k comparable, v any](keys Codec[k], values Codec[v]) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.keyCodec = keys
+		c.valueCodec = values
+	}
+}
+
+// WithAutoSnapshot saves the entries to the file at path every interval in the background, like Save,
+// and a last time when the SafeMap is closed, Close returning the error of that save. An unreachable SafeMap closed
+// automatically is saved a last time as well. The snapshot is taken by the worker
+// without copying the entries, see Snapshot, and encoded and written outside of it, so the operations are not blocked.
+// A failed periodic save is retried on the next interval. Restore the entries with NewSafeMapFromFile.
+// It can't be used with a ShardedSafeMap, see ShardedSafeMap.Save.
+// example
+//
+//	cache, err := NewSafeMapFromFile("cache.bin", WithAutoSnapshot[string, int]("cache.bin", time.Minute))
+func WithAutoSnapshot[k comparable, v any](path string, interval time.Duration) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.snapshotPath = path
+		c.snapshotInterval = interval
+	}
+}
+
+// WithWAL appends a record of every mutation to w, a write-ahead log restoring the entries with ReplayWAL,
+// so every write is durable and not only the periodic snapshots. Values written, with the deadline of their TTL,
+// and keys removed, including evictions and expirations, are logged with the codecs of WithCodec.
+// The records of a batch of operations are written to w, and synced if w has a Sync method such as *os.File,
+// before the operations return. Changing a TTL with Expire is logged, renewing it such as with Touch is not.
+// A failure to write stops the log, see WALError. The shards of a ShardedSafeMap share w,
+// their writes are serialized so the records of a batch are never interleaved with the ones of another shard.
+// example
+//
+//	f, err := os.OpenFile("state.wal", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	state := NewSafeMap(WithWAL[string, int](f))
+func WithWAL[k comparable, v any](w io.Writer) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.wal = w
+	}
+}
+
+// WithSubscriptionBuffer sets the number of events buffered for every subscriber of Subscribe, 1024 by default.
+// A subscriber falling behind by a full buffer is dropped, a larger buffer absorbs longer bursts of writes.
+// example
+//
+//	m := NewSafeMap(WithSubscriptionBuffer[string, int](1 << 16))
+func WithSubscriptionBuffer[k comparable, v any](n int) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.subscriptionBuffer = n
+	}
+}
+
+// withClock replaces time.Now as the source of the current time, it lets tests control the expiration of entries.
+func withClock[k comparable, v any](clock func() time.Time) Option[k, v] {
+	return func(c *config[k, v]) {
+		c.clock = clock
+	}
+}
+
+// bounded reports whether the SafeMap evicts entries, with WithMaxEntries or WithMaxBytes.
+func (c config[k, v]) bounded() bool {
+	return c.maxEntries > 0 || c.maxBytes > 0
+}
@@ -0,0 +1,367 @@
+package safemap
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCapacity(t *testing.T) {
+	m := NewSafeMap(WithCapacity[int, int](10), WithCapacity[int, int](100), WithQueueSize[int, int](8))
+	assert.Equal(t, 100, m.cfg.capacity)
+	assert.Equal(t, 8, cap(m.opChan))
+
+	m.Set(1, 1)
+	assert.Equal(t, 1, m.Get(1))
+
+	// options passed to NewSafeMapWithCapacity take precedence over its capacity
+	assert.Equal(t, 5, NewSafeMapWithCapacity(10, WithCapacity[int, int](5)).cfg.capacity)
+}
+
+func TestWithReadConcurrency(t *testing.T) {
+	m := NewSafeMap(WithReadConcurrency[int, int](4))
+
+	var wg sync.WaitGroup
+
+	wg.Add(5)
+
+	go func() {
+		for i := range 1000 {
+			m.Set(i, i)
+		}
+		wg.Done()
+	}()
+
+	for range 4 {
+		go func() {
+			for i := range 1000 {
+				if m.Exist(i) {
+					assert.Equal(t, i, m.Get(i))
+				}
+				m.Length()
+				m.GetMap()
+			}
+			wg.Done()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1000, m.Length())
+	for key, value := range m.All() {
+		assert.Equal(t, key, value)
+	}
+}
+
+func BenchmarkSafeMap_ReadConcurrency(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			m := NewSafeMap(WithReadConcurrency[int, int](n))
+			for i := range 1024 {
+				m.Set(i, i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					m.Get(i & 1023)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestWithMutex(t *testing.T) {
+	m := NewSafeMap(WithMutex[int, int]())
+
+	var wg sync.WaitGroup
+
+	wg.Add(4)
+
+	go func() {
+		for i := range 1000 {
+			m.Set(i, i)
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		for i := range 1000 {
+			m.Update(i, func(old int, exists bool) (int, bool) {
+				return old, exists
+			})
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		for i := range 1000 {
+			if val, ok := m.Lookup(i); ok {
+				assert.Equal(t, i, val)
+			}
+		}
+		wg.Done()
+	}()
+
+	go func() {
+		for range 100 {
+			m.GetMap()
+			m.Length()
+		}
+		wg.Done()
+	}()
+
+	wg.Wait()
+
+	assert.Equal(t, 1000, m.Length())
+	assert.Equal(t, 499500, Reduce(m, 0, func(acc, key, val int) int { return acc + val }))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		m.Update(1, func(int, bool) (int, bool) { panic("boom") })
+	})
+	assert.True(t, m.TrySet(1, 10))
+	assert.Equal(t, 10, m.Get(1))
+
+	clone := m.Clone()
+	assert.True(t, clone.Equal(m, nil))
+
+	assert.NoError(t, m.Close())
+	m.Set(1, 1)
+	assert.Equal(t, 0, m.Length())
+	assert.ErrorIs(t, m.SetCtx(context.Background(), 1, 1), ErrClosed)
+	assert.Equal(t, 1000, clone.Length())
+}
+
+func BenchmarkSafeMap_Mutex(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{name: "worker"},
+		{name: "mutex", opts: []Option[int, int]{WithMutex[int, int]()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			m := NewSafeMap(bench.opts...)
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if i%4 == 0 {
+						m.Set(i&1023, i)
+					} else {
+						m.Get(i & 1023)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestWithQueueSize(t *testing.T) {
+	m := NewSafeMap(WithQueueSize[int, int](4))
+
+	// keep the worker busy so the next operations wait in the queue
+	started, release := make(chan struct{}), make(chan struct{})
+	go m.Update(-1, func(old int, exists bool) (int, bool) {
+		close(started)
+		<-release
+		return old, true
+	})
+	<-started
+
+	for i := range 5 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		assert.ErrorIs(t, m.SetCtx(ctx, i, i), context.DeadlineExceeded)
+		cancel()
+	}
+
+	close(release)
+	// the queued operations are applied even though their callers gave up, the fifth one did not fit
+	assert.Equal(t, 5, m.Length())
+	assert.False(t, m.Exist(4))
+
+	started, release = make(chan struct{}), make(chan struct{})
+	go m.Update(-1, func(old int, exists bool) (int, bool) {
+		close(started)
+		<-release
+		return old, true
+	})
+	<-started
+
+	errc := make(chan error)
+	go func() {
+		errc <- m.SetCtx(context.Background(), 10, 10)
+	}()
+	assert.Eventually(t, func() bool { return len(m.opChan) == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, m.Close())
+	assert.ErrorIs(t, <-errc, ErrClosed)
+	close(release)
+}
+
+func TestWithSnapshotReads(t *testing.T) {
+	for _, opts := range [][]Option[int, int]{
+		{WithSnapshotReads[int, int]()},
+		{WithSnapshotReads[int, int](), WithMutex[int, int]()},
+	} {
+		m := NewSafeMap(opts...)
+
+		var wg sync.WaitGroup
+		wg.Add(5)
+		for w := range 4 {
+			go func() {
+				for i := w * 250; i < (w+1)*250; i++ {
+					m.Set(i, i)
+					// a write is visible to the reads started after it returned
+					assert.Equal(t, i, m.Get(i))
+				}
+				wg.Done()
+			}()
+		}
+		go func() {
+			for range 100 {
+				for key, val := range m.All() {
+					assert.Equal(t, key, val)
+				}
+			}
+			wg.Done()
+		}()
+		wg.Wait()
+
+		assert.Equal(t, 1000, m.Length())
+		assert.Equal(t, 1000, m.Clone().Length())
+
+		assert.PanicsWithValue(t, "boom", func() {
+			Reduce(m, 0, func(int, int, int) int { panic("boom") })
+		})
+		val, found, ok := m.TryGet(10)
+		assert.True(t, ok)
+		assert.True(t, found)
+		assert.Equal(t, 10, val)
+
+		assert.NoError(t, m.Close())
+		assert.Equal(t, 0, m.Length())
+		_, err := m.GetCtx(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrClosed)
+	}
+}
+
+func BenchmarkSafeMap_SnapshotReads(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{name: "worker"},
+		{name: "snapshot", opts: []Option[int, int]{WithSnapshotReads[int, int]()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			m := NewSafeMap(bench.opts...)
+			for i := range 1024 {
+				m.Set(i, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					m.Get(i & 1023)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestWithCopyOnWrite(t *testing.T) {
+	for _, opts := range [][]Option[int, int]{
+		{WithCopyOnWrite[int, int]()},
+		{WithCopyOnWrite[int, int](), WithSnapshotReads[int, int]()},
+		{WithCopyOnWrite[int, int](), WithMutex[int, int]()},
+		{WithCopyOnWrite[int, int](), WithReadConcurrency[int, int](4)},
+	} {
+		m := NewSafeMap(opts...)
+		for i := range 100 {
+			m.Set(i, i)
+		}
+
+		first := m.GetMap()
+		// without writes in between, the same version is handed out again
+		assert.Equal(t, reflect.ValueOf(first).UnsafePointer(), reflect.ValueOf(m.GetMap()).UnsafePointer())
+
+		clone := m.Clone()
+		m.Set(0, -1)
+		m.Delete(1)
+		assert.Equal(t, 0, first[0])
+		assert.Len(t, first, 100)
+		assert.Equal(t, -1, m.Get(0))
+		assert.Equal(t, 99, m.Length())
+
+		clone.Set(2, -2)
+		assert.Equal(t, 2, first[2])
+		assert.Equal(t, 2, m.Get(2))
+		assert.Equal(t, -2, clone.Get(2))
+
+		n := 0
+		for range m.All() {
+			m.Set(1000+n, 0)
+			n++
+		}
+		assert.Equal(t, 99, n)
+	}
+
+	assert.Panics(t, func() {
+		NewSafeMap(WithCopyOnWrite[int, int](), WithBackend[int, int](make(mapBackend[int, int])))
+	})
+}
+
+func BenchmarkSafeMap_CopyOnWrite(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option[int, int]
+	}{
+		{name: "copy"},
+		{name: "cow", opts: []Option[int, int]{WithCopyOnWrite[int, int]()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			m := NewSafeMap(bench.opts...)
+			for i := range 100_000 {
+				m.Set(i, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				m.GetMap()
+			}
+		})
+	}
+}
+
+func TestWithDefault(t *testing.T) {
+	m := NewSafeMap(WithDefault[string, int](func(key string) int { return len(key) }))
+	defer m.Close()
+
+	assert.Equal(t, 5, m.Get("hello"))
+	assert.False(t, m.Exist("hello"), "the default value is not stored")
+	_, ok := m.Lookup("hello")
+	assert.False(t, ok)
+
+	m.Set("hello", 0)
+	assert.Zero(t, m.Get("hello"), "a present zero value is not replaced")
+
+	val, err := m.GetCtx(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, val)
+
+	sharded := NewShardedSafeMap(2, WithDefault[string, int](func(string) int { return -1 }))
+	defer sharded.Close()
+	assert.Equal(t, -1, sharded.Get("missing"))
+}
@@ -0,0 +1,113 @@
+package safemap
+
+import (
+	"container/list"
+	"iter"
+)
+
+// orderedBackend is the Backend of a SafeMap created WithInsertionOrder, it iterates over the entries
+// in the order their keys were inserted.
+type orderedBackend[k comparable, v any] struct {
+	// order lists the entries from the oldest to the newest, its values are *orderedEntry.
+	order list.List
+	byKey map[k]*list.Element
+}
+
+// orderedEntry is an entry of an orderedBackend.
+type orderedEntry[k comparable, v any] struct {
+	key k
+	val v
+}
+
+// newOrderedBackend returns an empty orderedBackend pre-sized for capacity entries.
+func newOrderedBackend[k comparable, v any](capacity int) *orderedBackend[k, v] {
+	return &orderedBackend[k, v]{byKey: make(map[k]*list.Element, max(capacity, 0))}
+}
+
+func (o *orderedBackend[k, v]) Get(key k) (v, bool) {
+	if el, ok := o.byKey[key]; ok {
+		return el.Value.(*orderedEntry[k, v]).val, true
+	}
+	var zero v
+	return zero, false
+}
+
+// Set stores the value for key, a present key keeps its position.
+func (o *orderedBackend[k, v]) Set(key k, val v) {
+	if el, ok := o.byKey[key]; ok {
+		el.Value.(*orderedEntry[k, v]).val = val
+		return
+	}
+	o.byKey[key] = o.order.PushBack(&orderedEntry[k, v]{key: key, val: val})
+}
+
+func (o *orderedBackend[k, v]) Delete(key k) {
+	if el, ok := o.byKey[key]; ok {
+		o.order.Remove(el)
+		delete(o.byKey, key)
+	}
+}
+
+func (o *orderedBackend[k, v]) Len() int {
+	return len(o.byKey)
+}
+
+// All returns an iterator over the entries from the oldest to the newest.
+func (o *orderedBackend[k, v]) All() iter.Seq2[k, v] {
+	return func(yield func(k, v) bool) {
+		for el := o.order.Front(); el != nil; el = el.Next() {
+			entry := el.Value.(*orderedEntry[k, v])
+			if !yield(entry.key, entry.val) {
+				return
+			}
+		}
+	}
+}
+
+// keys returns the keys from the oldest to the newest.
+func (o *orderedBackend[k, v]) keys() []k {
+	keys := make([]k, 0, len(o.byKey))
+	for el := o.order.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*orderedEntry[k, v]).key)
+	}
+	return keys
+}
+
+// oldest returns the reply of the "oldest" operation.
+func (o *orderedBackend[k, v]) oldest() result[k, v] {
+	return entryReply[k, v](o.order.Front())
+}
+
+// newest returns the reply of the "newest" operation.
+func (o *orderedBackend[k, v]) newest() result[k, v] {
+	return entryReply[k, v](o.order.Back())
+}
+
+// entryReply returns the entry held by el, if any, as a reply.
+func entryReply[k comparable, v any](el *list.Element) result[k, v] {
+	if el == nil {
+		return result[k, v]{}
+	}
+	entry := el.Value.(*orderedEntry[k, v])
+	return result[k, v]{key: entry.key, value: entry.val, ok: true}
+}
+
+// Oldest returns the entry whose key was inserted first among the present ones.
+// The ok result is false if the SafeMap is empty. It panics unless the SafeMap was created WithInsertionOrder.
+// example
+//
+//	m := NewSafeMap(WithInsertionOrder[string, int]())
+//	m.Set("a", 1)
+//	m.Set("b", 2)
+//	key, val, _ := m.Oldest() // "a", 1
+func (s *SafeMap[k, v]) Oldest() (key k, val v, ok bool) {
+	reply := s.send(operation[k, v]{op: "oldest"})
+	return reply.key, reply.value, reply.ok
+}
+
+// Newest returns the entry whose key was inserted last among the present ones.
+// The ok result is false if the SafeMap is empty. It panics unless the SafeMap was created WithInsertionOrder.
+func (s *SafeMap[k, v]) Newest() (key k, val v, ok bool) {
+	reply := s.send(operation[k, v]{op: "newest"})
+	return reply.key, reply.value, reply.ok
+}
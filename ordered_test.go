@@ -0,0 +1,79 @@
+package safemap
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInsertionOrder(t *testing.T) {
+	m := NewSafeMap(WithInsertionOrder[string, int]())
+	defer m.Close()
+
+	_, _, ok := m.Oldest()
+	assert.False(t, ok)
+
+	for i, key := range []string{"e", "b", "d", "a", "c"} {
+		m.Set(key, i)
+	}
+	m.Set("b", 10)
+	m.Delete("d")
+	m.Set("d", 11)
+
+	assert.Equal(t, []string{"e", "b", "a", "c", "d"}, slices.Collect(m.Keys()), "an update keeps the position, a deletion forgets it")
+	assert.Equal(t, []int{0, 10, 3, 4, 11}, slices.Collect(m.Values()))
+	assert.Equal(t, []string{"e", "b", "a", "c", "d"}, slices.Collect(m.Snapshot().Keys()))
+	var ranged []string
+	m.Range(func(key string, _ int) bool {
+		ranged = append(ranged, key)
+		return true
+	})
+	assert.Equal(t, []string{"e", "b", "a", "c", "d"}, ranged)
+
+	key, val, ok := m.Oldest()
+	assert.True(t, ok)
+	assert.Equal(t, "e", key)
+	assert.Equal(t, 0, val)
+	key, val, ok = m.Newest()
+	assert.True(t, ok)
+	assert.Equal(t, "d", key)
+	assert.Equal(t, 11, val)
+
+	key, _, _ = m.Pop()
+	assert.Equal(t, "e", key, "Pop removes the oldest entry")
+
+	clone := m.Clone()
+	defer clone.Close()
+	clone.Set("f", 12)
+	assert.Equal(t, []string{"b", "a", "c", "d", "f"}, slices.Collect(clone.Keys()))
+}
+
+func TestWithInsertionOrder_FIFO(t *testing.T) {
+	m := NewSafeMap(WithInsertionOrder[int, int](), WithReadConcurrency[int, int](4))
+	defer m.Close()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+	for want := range 10 {
+		key, _, ok := m.Oldest()
+		assert.True(t, ok)
+		assert.Equal(t, want, key)
+		assert.True(t, m.CompareAndDelete(key, key))
+	}
+	assert.Zero(t, m.Length())
+}
+
+func TestWithInsertionOrder_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		NewSafeMap(WithInsertionOrder[string, int](), WithSnapshotReads[string, int]())
+	})
+	assert.Panics(t, func() {
+		NewShardedSafeMap(2, WithInsertionOrder[string, int]())
+	})
+
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	assert.PanicsWithValue(t, "safemap: oldest requires WithInsertionOrder", func() { m.Oldest() })
+}
@@ -0,0 +1,41 @@
+package safemap
+
+// Entry is a key and its value, as returned by Page.
+type Entry[k comparable, v any] struct {
+	Key   k
+	Value v
+}
+
+// Page returns at most limit entries of the SafeMap, skipping the first offset ones, along with the total number
+// of entries, read in a single operation. Only the entries up to the end of the page are visited, none are copied
+// besides the page itself. A limit lower than 1 only returns the total.
+//
+// The pages follow the order of iteration of the SafeMap: with WithInsertionOrder or a SafeSortedMap,
+// successive pages are stable as long as the map is not modified in between, and entries inserted meanwhile
+// shift the following pages at most. The order of a plain SafeMap is unspecified and may change between calls,
+// so its pages may overlap or miss entries.
+// example
+//
+//	entries, total := m.Page(page*50, 50)
+//	fmt.Fprintf(w, "showing %d of %d\n", len(entries), total)
+func (s *SafeMap[k, v]) Page(offset, limit int) (entries []Entry[k, v], total int) {
+	if limit > 0 {
+		entries = make([]Entry[k, v], 0, min(limit, 1024))
+	}
+	skipped := 0
+	total = s.send(operation[k, v]{
+		op: "forEach",
+		visit: func(key k, val v) bool {
+			if len(entries) >= limit {
+				return false
+			}
+			if skipped < offset {
+				skipped++
+				return true
+			}
+			entries = append(entries, Entry[k, v]{Key: key, Value: val})
+			return len(entries) < limit
+		},
+	}).n
+	return entries, total
+}
@@ -0,0 +1,46 @@
+package safemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Page(t *testing.T) {
+	m := NewSafeMap(WithInsertionOrder[int, string]())
+	defer m.Close()
+	for i := range 25 {
+		m.Set(i, "")
+	}
+
+	var keys []int
+	for offset := 0; ; offset += 10 {
+		entries, total := m.Page(offset, 10)
+		assert.Equal(t, 25, total)
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			keys = append(keys, entry.Key)
+		}
+	}
+	assert.Len(t, keys, 25)
+	for i, key := range keys {
+		assert.Equal(t, i, key, "the pages follow the insertion order")
+	}
+
+	entries, total := m.Page(0, 0)
+	assert.Nil(t, entries)
+	assert.Equal(t, 25, total)
+	entries, _ = m.Page(-5, 3)
+	assert.Equal(t, []Entry[int, string]{{0, ""}, {1, ""}, {2, ""}}, entries)
+
+	sorted := NewSafeSortedMap[string, int]()
+	defer sorted.Close()
+	for _, key := range []string{"d", "b", "a", "c"} {
+		sorted.Set(key, 0)
+	}
+	entries2, total := sorted.Page(1, 2)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []Entry[string, int]{{"b", 0}, {"c", 0}}, entries2)
+}
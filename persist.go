@@ -0,0 +1,129 @@
+package safemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Save writes the entries of the SafeMap to the file at path, in the format of MarshalBinary, see NewSafeMapFromFile.
+// The file is replaced atomically: the entries are written to a temporary file in the same directory,
+// which is synced and renamed over path, so a crash never leaves a partially written file behind.
+// example
+//
+//	if err := cache.Save("/var/lib/app/cache.bin"); err != nil {
+//		log.Printf("saving the cache: %v", err)
+//	}
+func (s *SafeMap[k, v]) Save(path string) error {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// writeFile replaces the file at path with data atomically.
+func writeFile(path string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// NewSafeMapFromFile creates a SafeMap with the options opts, holding the entries saved to the file at path by Save.
+// The codecs of WithCodec must match the ones used to save the file. If the file doesn't exist,
+// the error satisfies errors.Is(err, fs.ErrNotExist), so a first start can fall back to an empty map.
+// example
+//
+//	cache, err := NewSafeMapFromFile[string, int]("/var/lib/app/cache.bin")
+//	if errors.Is(err, fs.ErrNotExist) {
+//		cache = NewSafeMap[string, int]()
+//	} else if err != nil {
+//		log.Fatal(err)
+//	}
+func NewSafeMapFromFile[k comparable, v any](path string, opts ...Option[k, v]) (*SafeMap[k, v], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewSafeMap(opts...)
+	if err := m.UnmarshalBinary(data); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// autoSnapshot saves the entries to the file of WithAutoSnapshot every interval until the SafeMap is closed.
+func (c *core[k, v]) autoSnapshot(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// a failed save is retried on the next tick, the final save of Close reports its error
+			_ = c.saveSnapshot()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// saveSnapshot saves the entries to the file of WithAutoSnapshot. The snapshot is taken by the worker,
+// without copying the entries, and encoded and written outside of it so the operations go on meanwhile.
+// Saves are serialized, and none happens once the SafeMap is closed, so it never overwrites the final save of Close.
+func (c *core[k, v]) saveSnapshot() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	reply, err := c.deliver(context.Background(), operation[k, v]{op: "snapshot"})
+	if err != nil {
+		return err
+	}
+	return c.writeSnapshot(reply.items)
+}
+
+// writeSnapshot encodes items and writes them to the file of WithAutoSnapshot.
+func (c *core[k, v]) writeSnapshot(items map[k]v) error {
+	data, err := c.marshalBinary(items)
+	if err != nil {
+		return err
+	}
+	return writeFile(c.cfg.snapshotPath, data)
+}
+
+// closeSaving closes a SafeMap created with WithAutoSnapshot, after taking a last snapshot of its entries,
+// and returns the error of saving it.
+func (c *core[k, v]) closeSaving() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	reply, err := c.deliver(context.Background(), operation[k, v]{op: "snapshot"})
+	if err != nil {
+		return err
+	}
+	if err := c.close(); err != nil {
+		return err
+	}
+	return c.writeSnapshot(reply.items)
+}
@@ -0,0 +1,130 @@
+package safemap
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Save(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.bin")
+
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	assert.NoError(t, m.Save(path))
+
+	loaded, err := NewSafeMapFromFile[string, int](path)
+	assert.NoError(t, err)
+	defer loaded.Close()
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, loaded.GetMap())
+
+	// saving again replaces the file, leaving no temporary file behind
+	m.Delete("a")
+	assert.NoError(t, m.Save(path))
+	reloaded, err := NewSafeMapFromFile(path, WithMaxEntries[string, int](10))
+	assert.NoError(t, err)
+	defer reloaded.Close()
+	assert.Equal(t, map[string]int{"b": 2}, reloaded.GetMap())
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, m.Save(filepath.Join(dir, "missing", "cache.bin")))
+}
+
+func TestNewSafeMapFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewSafeMapFromFile[string, int](filepath.Join(dir, "missing.bin"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	path := filepath.Join(dir, "corrupt.bin")
+	assert.NoError(t, os.WriteFile(path, []byte("corrupt"), 0o600))
+	_, err = NewSafeMapFromFile[string, int](path)
+	assert.ErrorIs(t, err, errCorrupt)
+}
+
+func TestWithAutoSnapshot(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.bin")
+			load := func() map[string]int {
+				m, err := NewSafeMapFromFile[string, int](path)
+				if err != nil {
+					return nil
+				}
+				defer m.Close()
+				return m.GetMap()
+			}
+
+			m := NewSafeMap(append(opts, WithAutoSnapshot[string, int](path, 5*time.Millisecond))...)
+			m.Set("a", 1)
+			assert.Eventually(t, func() bool {
+				return assert.ObjectsAreEqual(map[string]int{"a": 1}, load())
+			}, time.Second, time.Millisecond)
+
+			// closing saves the last writes
+			m.Set("b", 2)
+			assert.NoError(t, m.Close())
+			assert.Equal(t, map[string]int{"a": 1, "b": 2}, load())
+			assert.ErrorIs(t, m.Close(), ErrClosed)
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, map[string]int{"a": 1, "b": 2}, load())
+		})
+	}
+
+	// the error of the final save is returned by Close
+	m := NewSafeMap(WithAutoSnapshot[string, int](filepath.Join(t.TempDir(), "missing", "cache.bin"), time.Hour))
+	m.Set("a", 1)
+	assert.Error(t, m.Close())
+}
+
+func TestWithAutoSnapshot_Cleanup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.bin")
+	func() {
+		m := NewSafeMap(WithAutoSnapshot[string, int](path, time.Hour))
+		m.Set("a", 1)
+	}()
+
+	// the last snapshot is saved when the unreachable SafeMap is closed
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		m, err := NewSafeMapFromFile[string, int](path)
+		if err != nil {
+			return false
+		}
+		defer m.Close()
+		return m.Get("a") == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestShardedSafeMap_Save(t *testing.T) {
+	assert.PanicsWithValue(t, "safemap: WithAutoSnapshot can't be used with a ShardedSafeMap", func() {
+		NewShardedSafeMap(4, WithAutoSnapshot[int, int](filepath.Join(t.TempDir(), "cache.bin"), time.Hour))
+	})
+
+	// the entries of every shard are saved to a single file
+	m := NewShardedSafeMap[int, int](4)
+	defer m.Close()
+	for i := range 100 {
+		m.Set(i, i)
+	}
+	path := filepath.Join(t.TempDir(), "cache.bin")
+	assert.NoError(t, m.Save(path))
+
+	loaded, err := NewSafeMapFromFile[int, int](path)
+	assert.NoError(t, err)
+	defer loaded.Close()
+	assert.Equal(t, m.GetMap(), loaded.GetMap())
+}
@@ -1,8 +1,46 @@
 package safemap
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
 	"iter"
 	"maps"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// stringMaxEntries is the number of entries printed by SafeMap.String.
+	stringMaxEntries = 10
+
+	// maxBatchSize is the number of queued operations the worker applies under a single lock,
+	// bounding how long readers served outside the worker wait for it.
+	maxBatchSize = 64
+
+	// defaultSubscriptionBuffer is the number of events buffered for a subscriber without WithSubscriptionBuffer.
+	defaultSubscriptionBuffer = 1024
+)
+
+var (
+	// ErrClosed is returned when closing a SafeMap that is already closed,
+	// or by context-aware operations used after the SafeMap was closed.
+	ErrClosed = errors.New("safemap: closed")
+
+	// ErrConflict is returned by UpdateWithRetry when the key kept being modified concurrently for all the attempts.
+	ErrConflict = errors.New("safemap: too many conflicting updates")
+
+	// errBusy reports that a non-blocking operation could not be applied immediately.
+	errBusy = errors.New("safemap: busy")
 )
 
 type (
@@ -12,124 +50,1394 @@ type (
 		op        string
 		key       k
 		value     v
-		replyChan chan any
+		replyChan chan result[k, v]
+
+		// to is the upper bound of the keys of between operations, key being the lower bound.
+		to k
+
+		// cursor holds the progress of the streaming iteration advanced by stream operations.
+		cursor *cursor[k, v]
+
+		// old and equal are used by the compare operations.
+		old   v
+		equal func(a, b v) bool
+
+		// update computes the new value of a key inside the worker.
+		update func(old v, exists bool) (v, bool)
+
+		// merge combines an existing value with the incoming one.
+		merge func(existing, incoming v) v
+
+		// items and keys hold the entries of batch operations.
+		items map[k]v
+		keys  []k
+
+		// match selects the entries a bulk operation applies to.
+		match func(key k, val v) bool
+
+		// transform rewrites the value of every entry.
+		transform func(key k, val v) v
+
+		// visit is called for every entry until it returns false.
+		visit func(key k, val v) bool
+
+		// ttl is the lifetime of the entry written by the operation, zero stands for the default TTL.
+		ttl time.Duration
+
+		// version is the version of the entry expected by the operation.
+		version uint64
+
+		// txn is the function run by a transaction.
+		txn func(tx Tx[k, v]) error
+
+		// ops holds the operations applied by Apply.
+		ops []Op[k, v]
+
+		// replay holds the records read back from a write-ahead log.
+		replay []walRecord[k, v]
+	}
+
+	// result is the reply of an operation. Each operation only fills the fields it needs,
+	// so replies travel through a typed channel without being boxed in an interface.
+	result[k comparable, v any] struct {
+		key   k
+		value v
+
+		// ok holds the boolean outcome of the operation, such as the presence of the key.
+		ok bool
+
+		// n holds the number of entries counted or affected by the operation.
+		n int
+
+		// items holds the entries returned by snapshot operations.
+		items map[k]v
+
+		// order holds the keys of items in insertion order, for a SafeMap created WithInsertionOrder.
+		// It holds the keys returned by keysSlice operations as well.
+		order []k
+
+		// values holds the values returned by valuesSlice operations.
+		values []v
+
+		// expiries holds the entries with a TTL returned by clone operations.
+		expiries []expiry[k]
+
+		// policy holds a copy of the built-in eviction policy returned by clone operations of a bounded SafeMap.
+		policy EvictionPolicy[k]
+
+		// ttl holds the remaining lifetime of an entry.
+		ttl time.Duration
+
+		// version holds the version of an entry.
+		version uint64
+
+		// results holds the results of the operations applied by Apply.
+		results []Result[v]
+
+		// err holds the error an operation failed with, such as the one returned by a transaction.
+		err error
+
+		// panicked carries a panic recovered in the worker back to the caller of the operation.
+		panicked any
+	}
+
+	// pendingReply is a reply waiting to be sent once its batch is applied.
+	pendingReply[k comparable, v any] struct {
+		replyChan chan result[k, v]
+		reply     result[k, v]
 	}
 
 	// SafeMap is a thread-safe map implementation using goroutines and channels.
 	// It supports concurrent access and modification of the map without the need for explicit locking.
-	// for initializing must use NewSafeMap function. if initialization NewSafeMap is not used will be panic if not used.
+	// The zero value is an empty map ready to use, its worker goroutine is started on first use.
+	// NewSafeMap is only needed to pass options. A SafeMap must not be copied after first use.
+	//
+	// SafeMap is a handle on the state owned by the worker goroutine. The worker never references the handle,
+	// so once a SafeMap becomes unreachable its worker is stopped automatically.
 	SafeMap[k comparable, v any] struct {
+		*core[k, v]
+
+		// once lazily initializes core for a zero value SafeMap.
+		once sync.Once
+	}
+
+	// core holds the state of a SafeMap shared with its worker goroutine.
+	core[k comparable, v any] struct {
 		opChan chan operation[k, v]
+
+		// mu guards store. The worker holds it while applying an operation,
+		// readers only take it when read concurrency is enabled.
+		// With WithMutex there is no worker and every caller applies its operation under mu.
+		mu    sync.RWMutex
+		store Backend[k, v]
+
+		// readSem bounds the number of readers bypassing the worker.
+		// It is nil unless WithReadConcurrency is used.
+		readSem chan struct{}
+
+		// replies recycles the reply channels of the operations sent to the worker.
+		replies sync.Pool
+
+		// snapshot is an immutable copy of the backend serving reads without locking.
+		// It is only maintained with WithSnapshotReads, and replaced after every batch of writes.
+		snapshot atomic.Pointer[mapBackend[k, v]]
+
+		// shared reports whether the current version of the entries was handed out, by Snapshot or with WithCopyOnWrite,
+		// such as by GetMap or as the snapshot. The next write then copies it first instead of modifying it.
+		shared atomic.Bool
+
+		// expiries tracks the entries with a TTL. hasExpiries reports whether there is any,
+		// read operations are then applied by the worker which removes expired entries first.
+		expiries    expiries[k]
+		hasExpiries atomic.Bool
+
+		// policy chooses the entries evicted with WithMaxEntries or WithMaxBytes, it is nil when the SafeMap is unbounded.
+		policy EvictionPolicy[k]
+
+		// sizes holds the size of every entry and bytes their sum, they are only maintained with WithMaxBytes
+		// or WithSizeEstimator.
+		sizes map[k]int
+		bytes int
+
+		// versions holds the version of every entry, it is nil until a version is asked for.
+		// version is the last version given, shared by the keys so a key set again never reuses a version.
+		versions map[k]uint64
+		version  uint64
+
+		// locked holds the keys locked by Do along with the operations on them held back until they are unlocked,
+		// locks counts them so reads skip the worker while no key is locked.
+		locked map[k][]operation[k, v]
+		locks  atomic.Int64
+
+		// flights deduplicates the concurrent loads of GetOrCompute.
+		flights flights[k, v]
+
+		// refreshing holds the keys being reloaded by WithRefreshAhead.
+		refreshing map[k]struct{}
+
+		// walBuf holds the records of the write-ahead log of WithWAL until the end of the batch,
+		// walErr the error that stopped it. replaying is set while records read back from it are applied.
+		walBuf    []byte
+		walErr    atomic.Pointer[error]
+		replaying bool
+
+		// saveMu serializes the saves of WithAutoSnapshot.
+		saveMu sync.Mutex
+
+		// stats counts the operations applied to the SafeMap.
+		stats counters
+
+		// latencies records the latency of the operations, it is nil until a Collector is created.
+		latencies atomic.Pointer[latencies]
+
+		// queued counts the operations handed over to the worker and not taken yet,
+		// blocked sums the time callers spent waiting for the worker or the queue to accept them.
+		queued  atomic.Int64
+		blocked atomic.Int64
+
+		// watchers holds the watchers of every watched key and subscribers the channels of the subscribers,
+		// both guarded by watchMu. watched counts them so writes skip the lock while nothing is watched.
+		watchMu     sync.Mutex
+		watchers    map[k][]*watcher[k, v]
+		subscribers map[chan Event[k, v]]struct{}
+		watched     atomic.Int64
+
+		// notifier hands the changes over to the callbacks of WithOnSet, WithOnDelete, WithOnExpire and WithOnEvict,
+		// it is nil without them.
+		notifier *notifier[k, v]
+
+		// seed hashes the keys reported to the hooks with WithKeyHashing.
+		seed maphash.Seed
+
+		// clock returns the current time, it is time.Now unless replaced in tests.
+		clock func() time.Time
+
+		// dirty reports whether the entries were modified since the snapshot was published.
+		dirty bool
+
+		// pending holds the replies of the batch being applied by the worker.
+		pending []pendingReply[k, v]
+
+		// cfg is the configuration the SafeMap was created with.
+		cfg config[k, v]
+
+		// done is closed by Close to stop the worker goroutine.
+		done      chan struct{}
+		closeOnce sync.Once
 	}
 )
 
 // NewSafeMap creates and returns a new instance of SafeMap.
 // It initializes the internal goroutine that processes operations on the map.
-func NewSafeMap[k comparable, v any]() *SafeMap[k, v] {
-	sm := &SafeMap[k, v]{
-		opChan: make(chan operation[k, v]),
+// The behaviour of the map can be tuned with options such as WithReadConcurrency.
+func NewSafeMap[k comparable, v any](opts ...Option[k, v]) *SafeMap[k, v] {
+	sm := &SafeMap[k, v]{}
+	sm.configure(opts)
+	return sm
+}
+
+// configure applies the options and starts the worker goroutine of the SafeMap,
+// for the types embedding a SafeMap to create it in place.
+func (s *SafeMap[k, v]) configure(opts []Option[k, v]) {
+	cfg := newConfig(opts)
+	s.init(cfg, cfg.newBackend())
+}
+
+// newConfig applies the options, it panics if some of them can't be used together.
+func newConfig[k comparable, v any](opts []Option[k, v]) config[k, v] {
+	var cfg config[k, v]
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-	data := make(map[k]v)
 
-	go func() {
-		for op := range sm.opChan {
-			switch op.op {
-			case "set":
-				data[op.key] = op.value
-				op.replyChan <- struct{}{}
-			case "get":
-				op.replyChan <- data[op.key]
-			case "delete":
-				delete(data, op.key)
-				op.replyChan <- struct{}{}
-			case "exist":
-				_, ok := data[op.key]
-				op.replyChan <- ok
-			case "getMap":
-				copyMap := make(map[k]v, len(data))
-				maps.Copy(copyMap, data)
-				op.replyChan <- copyMap
-			case "getLen":
-				op.replyChan <- len(data)
+	if cfg.copyOnWrite && cfg.backend != nil {
+		panic("safemap: WithCopyOnWrite can't be used with WithBackend")
+	}
+	if cfg.insertionOrder && (cfg.backend != nil || cfg.copyOnWrite || cfg.snapshotReads) {
+		panic("safemap: WithInsertionOrder can't be used with WithBackend, WithCopyOnWrite or WithSnapshotReads")
+	}
+	if cfg.newStore != nil && (cfg.backend != nil || cfg.copyOnWrite || cfg.snapshotReads || cfg.insertionOrder) {
+		panic("safemap: a " + cfg.kind + " can't be used with WithBackend, WithCopyOnWrite, WithSnapshotReads or WithInsertionOrder")
+	}
+	return cfg
+}
+
+// newBackend returns the Backend holding the entries of a SafeMap created with cfg.
+func (cfg *config[k, v]) newBackend() Backend[k, v] {
+	switch {
+	case cfg.newStore != nil:
+		return cfg.newStore(cfg.capacity)
+	case cfg.insertionOrder:
+		return newOrderedBackend[k, v](cfg.capacity)
+	case cfg.backend != nil:
+		return cfg.backend
+	}
+	return make(mapBackend[k, v], max(cfg.capacity, 0))
+}
+
+// NewSafeMapWithCapacity creates a SafeMap whose internal map is pre-sized to hold n entries,
+// so filling it doesn't repeatedly grow the map inside the worker goroutine.
+// The capacity is a hint, the map still grows past n entries. It is ignored with WithBackend.
+// example
+//
+//	m := NewSafeMapWithCapacity[string, int](1_000_000)
+func NewSafeMapWithCapacity[k comparable, v any](n int, opts ...Option[k, v]) *SafeMap[k, v] {
+	return NewSafeMap(append([]Option[k, v]{WithCapacity[k, v](n)}, opts...)...)
+}
+
+// NewSafeMapFrom creates a SafeMap holding a copy of the entries of m.
+// The entries are loaded in a single operation into a map pre-sized for them, m is not retained.
+// example
+//
+//	m := NewSafeMapFrom(map[string]int{"a": 1, "b": 2})
+func NewSafeMapFrom[k comparable, v any](m map[k]v, opts ...Option[k, v]) *SafeMap[k, v] {
+	sm := NewSafeMapWithCapacity(len(m), opts...)
+	sm.SetMany(m)
+	return sm
+}
+
+// newSafeMap creates a SafeMap over store and starts its worker goroutine.
+func newSafeMap[k comparable, v any](cfg config[k, v], store Backend[k, v]) *SafeMap[k, v] {
+	sm := &SafeMap[k, v]{}
+	sm.init(cfg, store)
+	return sm
+}
+
+// init starts the worker goroutine of the SafeMap over store.
+func (s *SafeMap[k, v]) init(cfg config[k, v], store Backend[k, v]) {
+	s.start(newCore(cfg, store))
+}
+
+// newCore creates the state of a SafeMap over store, without starting its goroutines
+// so the caller may fill it first, as Clone does.
+func newCore[k comparable, v any](cfg config[k, v], store Backend[k, v]) *core[k, v] {
+	c := &core[k, v]{
+		opChan: make(chan operation[k, v], max(cfg.queueSize, 0)),
+		store:  store,
+		cfg:    cfg,
+		done:   make(chan struct{}),
+		policy: newPolicy(cfg),
+	}
+	if cfg.keyHashing {
+		c.seed = maphash.MakeSeed()
+	}
+	c.clock = cfg.clock
+	if c.clock == nil {
+		c.clock = time.Now
+	}
+	c.replies.New = func() any {
+		return make(chan result[k, v], 1)
+	}
+	if cfg.readConcurrency > 1 {
+		c.readSem = make(chan struct{}, cfg.readConcurrency)
+	}
+
+	if cfg.snapshotReads {
+		c.publish()
+	}
+	return c
+}
+
+// start starts the goroutines of c and makes it the state of the SafeMap.
+// A cleanup is attached to the handle, closing the map when the handle becomes unreachable.
+func (s *SafeMap[k, v]) start(c *core[k, v]) {
+	cfg := c.cfg
+	if !cfg.mutex {
+		go c.run()
+	}
+	if cfg.cleanupInterval > 0 {
+		go c.janitor(cfg.cleanupInterval)
+	}
+	if cfg.snapshotPath != "" && cfg.snapshotInterval > 0 {
+		go c.autoSnapshot(cfg.snapshotInterval)
+	}
+	if cfg.onSet != nil || cfg.onDelete != nil || cfg.onExpire != nil || cfg.onEvict != nil {
+		c.notifier = &notifier[k, v]{wake: make(chan struct{}, 1)}
+		go c.notify()
+	}
+
+	s.core = c
+	// the last snapshot of WithAutoSnapshot goes through the worker, so it is taken on a goroutine of its own
+	runtime.AddCleanup(s, func(c *core[k, v]) { go c.shutdown() }, c)
+}
+
+// load returns the state of the SafeMap, initializing it with the default configuration
+// on first use of a zero value SafeMap.
+func (s *SafeMap[k, v]) load() *core[k, v] {
+	s.once.Do(func() {
+		if s.core == nil {
+			s.init(config[k, v]{}, make(mapBackend[k, v]))
+		}
+	})
+	return s.core
+}
+
+// send delivers the operation to the worker of the SafeMap, see core.send.
+func (s *SafeMap[k, v]) send(op operation[k, v]) result[k, v] {
+	reply := s.load().send(op)
+	// the handle is kept alive until the reply, so the cleanup doesn't close the SafeMap while op is in flight
+	runtime.KeepAlive(s)
+	return reply
+}
+
+// sendCtx delivers the operation to the worker of the SafeMap, see core.sendCtx.
+func (s *SafeMap[k, v]) sendCtx(ctx context.Context, op operation[k, v]) (result[k, v], error) {
+	reply, err := s.load().sendCtx(ctx, op)
+	runtime.KeepAlive(s)
+	return reply, err
+}
+
+// trySend delivers the operation to the worker of the SafeMap, see core.trySend.
+func (s *SafeMap[k, v]) trySend(op operation[k, v]) (result[k, v], bool) {
+	reply, ok := s.load().trySend(op)
+	runtime.KeepAlive(s)
+	return reply, ok
+}
+
+// sendAsync delivers the operation to the worker of the SafeMap, see core.sendAsync.
+func (s *SafeMap[k, v]) sendAsync(op operation[k, v]) {
+	s.load().sendAsync(op)
+}
+
+// run is the worker loop, it applies every operation sent on opChan in order until the SafeMap is closed.
+func (c *core[k, v]) run() {
+	for {
+		select {
+		case op := <-c.opChan:
+			c.applyBatch(op)
+		case <-c.done:
+			c.mu.Lock()
+			c.release()
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+// release drops the entries of a closed SafeMap, the caller must hold mu.
+func (c *core[k, v]) release() {
+	c.store = nil
+	c.expiries = expiries[k]{}
+	c.hasExpiries.Store(false)
+	c.policy = newPolicy(c.cfg)
+	c.sizes, c.bytes = nil, 0
+	c.versions = nil
+	c.locked = nil
+	c.refreshing = nil
+	c.closeSubscribers()
+	if c.cfg.snapshotReads {
+		c.snapshot.Store(new(mapBackend[k, v]))
+	}
+}
+
+// applyBatch applies op and the operations already queued behind it, up to maxBatchSize, under a single write lock.
+// Draining a burst in one pass saves a lock round trip and a wake up of the worker per operation.
+// Every reply is sent as soon as its operation is applied, the reply channels are buffered so this never blocks.
+func (c *core[k, v]) applyBatch(op operation[k, v]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n := 1; op.op != ""; n++ {
+		c.queued.Add(-1)
+		c.step(op)
+
+		op = operation[k, v]{}
+		if n < maxBatchSize {
+			select {
+			case op = <-c.opChan:
+			default:
 			}
 		}
+	}
+
+	// the mutations are logged before replying, so they are durable once acknowledged
+	if c.cfg.wal != nil {
+		c.flushWAL()
+	}
+	// the snapshot is published before replying, so callers always read their own writes
+	if c.dirty && c.cfg.snapshotReads {
+		c.publish()
+	}
+	for i, p := range c.pending {
+		p.replyChan <- p.reply
+		c.pending[i] = pendingReply[k, v]{}
+	}
+	c.pending = c.pending[:0]
+}
+
+// publish replaces the snapshot served to readers with a copy of the backend, the caller must hold mu.
+func (c *core[k, v]) publish() {
+	c.dirty = false
+	if c.cfg.copyOnWrite {
+		// the current version becomes the snapshot, the next write copies it
+		store := c.store.(mapBackend[k, v])
+		c.shared.Store(true)
+		c.snapshot.Store(&store)
+		return
+	}
+
+	snapshot := make(mapBackend[k, v], c.store.Len())
+	maps.Insert(snapshot, c.store.All())
+	c.snapshot.Store(&snapshot)
+}
+
+// applySnapshot applies a read operation to the latest published snapshot, in the calling goroutine and without locking.
+func (c *core[k, v]) applySnapshot(op operation[k, v]) (result[k, v], error) {
+	select {
+	case <-c.done:
+		return result[k, v]{}, ErrClosed
+	default:
+	}
+
+	snapshot := *c.snapshot.Load()
+	if op.op == "getMap" && c.cfg.copyOnWrite {
+		return result[k, v]{items: snapshot}, nil
+	}
+	return c.read(snapshot, op), nil
+}
+
+// applyRecover applies the operation, the caller must hold the write lock.
+// A panic raised while applying, typically by a user supplied function, is recovered
+// and returned in the reply so it is re-raised in the caller instead of killing the worker.
+func (c *core[k, v]) applyRecover(op operation[k, v]) (reply result[k, v]) {
+	defer func() {
+		if r := recover(); r != nil {
+			reply = result[k, v]{panicked: r}
+		}
 	}()
 
-	return sm
+	c.expire()
+	return c.apply(op)
 }
 
-// Set sets the value for the given key in the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
-func (s *SafeMap[k, v]) Set(key k, val v) {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+// applyShared applies a read operation under the read lock, in the calling goroutine.
+// It gives up with the context error if ctx is done before a reader slot is available.
+func (c *core[k, v]) applyShared(ctx context.Context, op operation[k, v]) (result[k, v], error) {
+	select {
+	case <-c.done:
+		return result[k, v]{}, ErrClosed
+	default:
+	}
+
+	select {
+	case c.readSem <- struct{}{}:
+	case <-ctx.Done():
+		return result[k, v]{}, ctx.Err()
 	}
+	c.mu.RLock()
+	defer func() {
+		c.mu.RUnlock()
+		<-c.readSem
+	}()
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "set",
-		key:       key,
-		value:     val,
-		replyChan: replyChan,
+	// the map may have been closed while waiting for the lock
+	select {
+	case <-c.done:
+		return result[k, v]{}, ErrClosed
+	default:
 	}
-	<-replyChan
+
+	return c.apply(op), nil
 }
 
-// Get retrieves the value for the given key from the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
-func (s *SafeMap[k, v]) Get(key k) (val v) {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+// applyDirect applies the operation in the calling goroutine when the SafeMap runs without a worker.
+// Read operations take the read lock and every other operation the write lock.
+// With try set, it returns errBusy instead of waiting for the lock.
+func (c *core[k, v]) applyDirect(op operation[k, v], try bool) (result[k, v], error) {
+	lock, unlock := c.mu.Lock, c.mu.Unlock
+	tryLock := c.mu.TryLock
+	shared := c.bypass(op.op)
+	if shared {
+		lock, unlock = c.mu.RLock, c.mu.RUnlock
+		tryLock = c.mu.TryRLock
+	}
+
+	if !try {
+		lock()
+	} else if !tryLock() {
+		return result[k, v]{}, errBusy
 	}
+	defer unlock()
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "get",
-		key:       key,
-		replyChan: replyChan,
+	select {
+	case <-c.done:
+		return result[k, v]{}, ErrClosed
+	default:
 	}
 
-	reply := <-replyChan
-	return reply.(v)
+	if !shared {
+		if c.cfg.snapshotReads {
+			defer func() {
+				if c.dirty {
+					c.publish()
+				}
+			}()
+		}
+		if c.cfg.wal != nil {
+			defer c.flushWAL()
+		}
+		c.expire()
+	}
+	return c.apply(op), nil
 }
 
-// Delete removes the key-value pair for the given key from the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
-func (s *SafeMap[k, v]) Delete(key k) {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+// apply executes a single operation against the backend and returns its reply.
+// The caller must hold mu, read operations only need the read lock.
+// Cases ranging over the backend copy the fields of op they need and don't return from the loop body,
+// so neither op nor the reply is moved to the heap for the other operations.
+func (c *core[k, v]) apply(op operation[k, v]) result[k, v] {
+	if c.cfg.copyOnWrite && op.op == "getMap" {
+		c.shared.Store(true)
+		return result[k, v]{items: c.store.(mapBackend[k, v])}
+	}
+
+	if isReadOp(op.op) {
+		// reads only run under the read lock while no read is tracked, see bypass
+		if c.cfg.bounded() || (c.cfg.sliding || c.cfg.refreshAhead > 0) && c.expiries.Len() > 0 {
+			switch op.op {
+			case "get", "lookup":
+				c.access(op.key)
+			case "getMany":
+				for _, key := range op.keys {
+					c.access(key)
+				}
+			}
+		}
+		return c.read(c.store, op)
+	}
+
+	switch op.op {
+	case "set":
+		c.set(op.key, op.value, op.ttl)
+		return result[k, v]{}
+	case "delete":
+		c.remove(op.key)
+		return result[k, v]{}
+	case "setUnique":
+		// the key previously holding the value is removed first, so the value is held by a single key
+		if other, ok := c.store.(inverseStore[k, v]).keyOf(op.value); ok && other != op.key {
+			c.remove(other)
+		}
+		c.set(op.key, op.value, op.ttl)
+		return result[k, v]{}
+	case "deleteByValue":
+		key, ok := c.store.(inverseStore[k, v]).keyOf(op.value)
+		if ok {
+			c.remove(key)
+		}
+		return result[k, v]{key: key, value: op.value, ok: ok}
+	case "getOrSet":
+		val, ok := c.store.Get(op.key)
+		c.stats.lookup(ok)
+		if ok {
+			return result[k, v]{key: op.key, value: val, ok: true}
+		}
+		c.set(op.key, op.value, 0)
+		return result[k, v]{key: op.key, value: op.value}
+	case "getAndDelete":
+		val, ok := c.store.Get(op.key)
+		if ok {
+			c.remove(op.key)
+		}
+		return result[k, v]{key: op.key, value: val, ok: ok}
+	case "swap":
+		val, ok := c.store.Get(op.key)
+		c.set(op.key, op.value, 0)
+		return result[k, v]{key: op.key, value: val, ok: ok}
+	case "compareAndSwap":
+		if val, ok := c.store.Get(op.key); ok && op.equal(val, op.old) {
+			c.put(op.key, op.value)
+			return result[k, v]{ok: true}
+		}
+		return result[k, v]{}
+	case "compareAndDelete":
+		if val, ok := c.store.Get(op.key); ok && op.equal(val, op.old) {
+			c.remove(op.key)
+			return result[k, v]{ok: true}
+		}
+		return result[k, v]{}
+	case "clear":
+		keys := slices.Collect(c.keys())
+		for _, key := range keys {
+			c.remove(key)
+		}
+		return result[k, v]{n: len(keys)}
+	case "lock":
+		if c.locked == nil {
+			c.locked = make(map[k][]operation[k, v])
+		}
+		c.locked[op.key] = nil
+		c.locks.Add(1)
+		val, ok := c.store.Get(op.key)
+		return result[k, v]{key: op.key, value: val, ok: ok}
+	case "update", "unlock":
+		if op.update == nil {
+			// the function of Do panicked, the key is released as is
+			val, ok := c.store.Get(op.key)
+			return result[k, v]{key: op.key, value: val, ok: ok}
+		}
+		old, exists := c.store.Get(op.key)
+		val, keep := op.update(old, exists)
+		if !keep {
+			if exists {
+				c.remove(op.key)
+			}
+			return result[k, v]{key: op.key}
+		}
+		if exists {
+			c.put(op.key, val)
+		} else {
+			c.set(op.key, val, 0)
+		}
+		return result[k, v]{key: op.key, value: val, ok: true}
+	case "upsert":
+		existing, ok := c.store.Get(op.key)
+		if !ok {
+			c.set(op.key, op.value, 0)
+			return result[k, v]{value: op.value}
+		}
+		val := op.merge(existing, op.value)
+		c.put(op.key, val)
+		return result[k, v]{value: val}
+	case "setIfAbsent":
+		if _, ok := c.store.Get(op.key); ok {
+			return result[k, v]{}
+		}
+		c.set(op.key, op.value, 0)
+		return result[k, v]{ok: true}
+	case "setIfPresent":
+		if _, ok := c.store.Get(op.key); !ok {
+			return result[k, v]{}
+		}
+		c.put(op.key, op.value)
+		return result[k, v]{ok: true}
+	case "pop":
+		var popped result[k, v]
+		for key, val := range c.store.All() {
+			popped = result[k, v]{key: key, value: val, ok: true}
+			break
+		}
+		if popped.ok {
+			c.remove(popped.key)
+		}
+		return popped
+	case "setMany":
+		for key, val := range op.items {
+			c.set(key, val, 0)
+		}
+		return result[k, v]{}
+	case "sizeBytes":
+		return result[k, v]{n: c.bytes}
+	case "streamNext":
+		return c.advance(op.cursor)
+	case "streamStop":
+		op.cursor.stop()
+		return result[k, v]{}
+	case "deleteNamespace":
+		keys := c.store.(namespacedStore[k, v]).keysIn(op.key)
+		for _, key := range keys {
+			c.remove(key)
+		}
+		return result[k, v]{n: len(keys)}
+	case "deleteMany":
+		n := 0
+		for _, key := range op.keys {
+			if _, ok := c.store.Get(key); ok {
+				c.remove(key)
+				n++
+			}
+		}
+		return result[k, v]{n: n}
+	case "deleteFunc":
+		var keys []k
+		match := op.match
+		for key, val := range c.store.All() {
+			if match(key, val) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			c.remove(key)
+		}
+		return result[k, v]{n: len(keys)}
+	case "transformValues":
+		items := make(map[k]v, c.store.Len())
+		transform := op.transform
+		for key, val := range c.store.All() {
+			items[key] = transform(key, val)
+		}
+		for key, val := range items {
+			c.put(key, val)
+		}
+		return result[k, v]{}
+	case "forEachLocked":
+		// unlike forEach, it is never served from a snapshot or under the read lock, so writes wait for it
+		visit := op.visit
+		for key, val := range c.store.All() {
+			if !visit(key, val) {
+				break
+			}
+		}
+		return result[k, v]{}
+	case "getVersioned":
+		val, ok := c.store.Get(op.key)
+		c.stats.lookup(ok)
+		if !ok {
+			return result[k, v]{}
+		}
+		c.access(op.key)
+		ver, _ := c.versionOf(op.key)
+		return result[k, v]{value: val, version: ver, ok: true}
+	case "setIfVersion", "deleteIfVersion":
+		ver, exists := c.versionOf(op.key)
+		if ver != op.version {
+			return result[k, v]{}
+		}
+		if op.op == "deleteIfVersion" {
+			c.remove(op.key)
+		} else if exists {
+			c.put(op.key, op.value)
+		} else {
+			c.set(op.key, op.value, 0)
+		}
+		return result[k, v]{ok: true}
+	case "getTTL":
+		if _, ok := c.store.Get(op.key); !ok {
+			return result[k, v]{}
+		}
+		exp, ok := c.expiries.byKey[op.key]
+		if !ok {
+			return result[k, v]{ok: true}
+		}
+		return result[k, v]{ttl: exp.deadline.Sub(c.clock()), ok: true}
+	case "touch", "expire":
+		val, ok := c.store.Get(op.key)
+		if !ok {
+			return result[k, v]{}
+		}
+		if op.op == "touch" {
+			c.touch(op.key)
+			return result[k, v]{ok: true}
+		}
+		if op.ttl > 0 {
+			c.expiries.set(op.key, op.ttl, c.clock().Add(op.ttl))
+			c.hasExpiries.Store(true)
+		} else {
+			c.expiries.delete(op.key)
+		}
+		// the entry is logged again with its new deadline, so replaying the log restores it
+		if c.cfg.wal != nil {
+			c.logSet(op.key, val)
+		}
+		return result[k, v]{ok: true}
+	case "replay":
+		c.replay(op.replay)
+		return result[k, v]{}
+	case "applyOps":
+		return result[k, v]{results: c.applyOps(op.ops)}
+	case "txn":
+		return result[k, v]{err: c.transact(op.txn)}
+	case "refreshed", "refreshFailed":
+		delete(c.refreshing, op.key)
+		if ver, ok := c.versionOf(op.key); ok && ver == op.version && op.op == "refreshed" {
+			c.set(op.key, op.value, op.ttl)
+		}
+		return result[k, v]{}
+	case "snapshot":
+		if store, ok := c.store.(mapBackend[k, v]); ok {
+			c.shared.Store(true)
+			return result[k, v]{items: store}
+		}
+		return applyRead(c.store, operation[k, v]{op: "getMap"})
+	case "sweep":
+		// expired entries were already removed before applying the operation
+		return result[k, v]{}
+	case "clone":
+		reply := c.apply(operation[k, v]{op: "getMap"})
+		if c.expiries.Len() > 0 {
+			reply.expiries = c.expiries.list()
+		}
+		if cloner, ok := c.policy.(policyCloner[k]); ok {
+			reply.policy = cloner.clone()
+		}
+		return reply
+	}
+	return result[k, v]{}
+}
+
+// put stores the value of key, keeping its TTL. The caller must hold the write lock.
+// With WithCopyOnWrite, a version that was handed out is copied before it is modified.
+func (c *core[k, v]) put(key k, val v) {
+	c.own()
+	c.store.Set(key, val)
+	c.dirty = true
+	c.bump(key)
+	if c.cfg.wal != nil && !c.replaying {
+		c.logSet(key, val)
+	}
+	c.stats.sets.Add(1)
+	if c.notifies(0) {
+		c.notifier.push(notification[k, v]{key: key, value: val})
+	}
+	if c.watched.Load() > 0 {
+		c.watch(notification[k, v]{key: key, value: val})
 	}
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "delete",
-		key:       key,
-		replyChan: replyChan,
+	if c.cfg.sizeOf != nil {
+		size := c.cfg.sizeOf(key, val)
+		if c.cfg.maxBytes > 0 && size > c.cfg.maxBytes {
+			c.removeFor(key, EvictionCapacity)
+			return
+		}
+		if c.sizes == nil {
+			c.sizes = make(map[k]int)
+		}
+		c.bytes += size - c.sizes[key]
+		c.sizes[key] = size
 	}
 
-	<-replyChan
+	if c.policy == nil {
+		return
+	}
+	c.policy.RecordInsert(key)
+	c.evict()
 }
 
-// Exist checks if the given key exists in the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
-func (s *SafeMap[k, v]) Exist(key k) bool {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+// set stores the value of key, replacing its TTL with ttl. The caller must hold the write lock.
+// A zero ttl stands for the default TTL of the SafeMap and noTTL for no expiry.
+func (c *core[k, v]) set(key k, val v, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = c.cfg.defaultTTL
+	}
+	if ttl > 0 {
+		c.expiries.set(key, ttl, c.clock().Add(ttl))
+		c.hasExpiries.Store(true)
+	} else {
+		c.expiries.delete(key)
+	}
+
+	// the TTL is set first, so it is dropped along with the entry if the entry doesn't fit the bounds
+	c.put(key, val)
+}
+
+// remove deletes key along with its TTL, as an explicit deletion. The caller must hold the write lock.
+func (c *core[k, v]) remove(key k) {
+	c.removeFor(key, EvictionDeleted)
+}
+
+// removeFor deletes key along with its TTL, reporting it to the callbacks as removed for reason.
+// The caller must hold the write lock.
+func (c *core[k, v]) removeFor(key k, reason EvictionReason) {
+	if val, ok := c.store.Get(key); ok {
+		if c.cfg.wal != nil && !c.replaying {
+			c.logDelete(key)
+		}
+		c.stats.removed(reason)
+		if c.notifies(reason) {
+			c.notifier.push(notification[k, v]{key: key, value: val, reason: reason})
+		}
+		if c.watched.Load() > 0 {
+			c.watch(notification[k, v]{key: key, value: val, reason: reason})
+		}
+	}
+
+	c.own()
+	c.store.Delete(key)
+	c.expiries.delete(key)
+	if c.versions != nil {
+		delete(c.versions, key)
+	}
+	if c.policy != nil {
+		c.policy.Remove(key)
+	}
+	if c.sizes != nil {
+		c.bytes -= c.sizes[key]
+		delete(c.sizes, key)
+	}
+	c.dirty = true
+}
+
+// access records that the value of key was read. The caller must hold the write lock.
+func (c *core[k, v]) access(key k) {
+	if c.cfg.sliding {
+		c.touch(key)
+	}
+	if c.cfg.refreshAhead > 0 {
+		c.refresh(key)
+	}
+	if c.policy != nil {
+		if _, ok := c.store.Get(key); ok {
+			c.policy.RecordAccess(key)
+		}
+	}
+}
+
+// touch restarts the TTL of key, if it has one. The caller must hold the write lock.
+func (c *core[k, v]) touch(key k) {
+	if exp, ok := c.expiries.byKey[key]; ok {
+		c.expiries.set(key, exp.ttl, c.clock().Add(exp.ttl))
+	}
+}
+
+// own copies the current version of the entries before it is modified if it was handed out, see shared.
+func (c *core[k, v]) own() {
+	if c.shared.Load() {
+		c.store = maps.Clone(c.store.(mapBackend[k, v]))
+		c.shared.Store(false)
+	}
+}
+
+// expire removes the entries whose TTL has elapsed. The caller must hold the write lock.
+func (c *core[k, v]) expire() {
+	if c.expiries.Len() > 0 {
+		now := c.clock()
+		for exp, ok := c.expiries.next(); ok && !exp.deadline.After(now); exp, ok = c.expiries.next() {
+			c.removeFor(exp.key, EvictionExpired)
+		}
+	}
+
+	// the last expiry may also be gone through a write or Expire
+	if c.expiries.Len() == 0 && c.hasExpiries.Load() {
+		c.hasExpiries.Store(false)
 	}
+}
+
+// bypass reports whether the operation may be applied outside the worker or under the read lock.
+// Only read operations qualify, and only while no entry has a TTL: expired entries are removed
+// by the worker before applying an operation, so they are never observed.
+// When the SafeMap is bounded, reads update the order of use and never qualify,
+// nor do they while a key is locked by Do, since the reads of the key are held back.
+func (c *core[k, v]) bypass(op string) bool {
+	return isReadOp(op) && !c.cfg.bounded() && !c.hasExpiries.Load() && c.locks.Load() == 0
+}
+
+// applyRead executes a read operation against store, which is either the backend or a snapshot of it.
+// Like apply, it keeps op and the reply on the stack.
+func applyRead[k comparable, v any](store Backend[k, v], op operation[k, v]) result[k, v] {
+	switch op.op {
+	case "get":
+		val, ok := store.Get(op.key)
+		return result[k, v]{value: val, ok: ok}
+	case "lookup":
+		val, ok := store.Get(op.key)
+		return result[k, v]{key: op.key, value: val, ok: ok}
+	case "exist":
+		_, ok := store.Get(op.key)
+		return result[k, v]{ok: ok}
+	case "getMap":
+		copyMap := make(map[k]v, store.Len())
+		maps.Insert(copyMap, store.All())
+		reply := result[k, v]{items: copyMap}
+		if ordered, ok := store.(interface{ keys() []k }); ok {
+			reply.order = ordered.keys()
+		}
+		return reply
+	case "getLen":
+		return result[k, v]{n: store.Len()}
+	case "random":
+		n := store.Len()
+		if n == 0 {
+			return result[k, v]{}
+		}
+		// the i-th entry of any order is uniformly random when i is uniformly random
+		var (
+			key k
+			val v
+		)
+		i := rand.IntN(n)
+		for kk, vv := range store.All() {
+			if i == 0 {
+				key, val = kk, vv
+				break
+			}
+			i--
+		}
+		return result[k, v]{key: key, value: val, ok: true}
+	case "keysSlice":
+		keys := make([]k, 0, store.Len())
+		for key := range store.All() {
+			keys = append(keys, key)
+		}
+		return result[k, v]{order: keys}
+	case "valuesSlice":
+		values := make([]v, 0, store.Len())
+		for _, val := range store.All() {
+			values = append(values, val)
+		}
+		return result[k, v]{values: values}
+	case "getMany":
+		found := make(map[k]v, len(op.keys))
+		for _, key := range op.keys {
+			if val, ok := store.Get(key); ok {
+				found[key] = val
+			}
+		}
+		return result[k, v]{items: found}
+	case "forEach":
+		visit := op.visit
+		for key, val := range store.All() {
+			if !visit(key, val) {
+				break
+			}
+		}
+		return result[k, v]{n: store.Len()}
+	case "equal":
+		if store.Len() != len(op.items) {
+			return result[k, v]{}
+		}
+		items, equal := op.items, op.equal
+		same := true
+		for key, val := range store.All() {
+			if other, ok := items[key]; !ok || !equal(val, other) {
+				same = false
+				break
+			}
+		}
+		return result[k, v]{ok: same}
+	case "oldest", "newest":
+		ordered, ok := store.(*orderedBackend[k, v])
+		if !ok {
+			panic("safemap: " + op.op + " requires WithInsertionOrder")
+		}
+		if op.op == "oldest" {
+			return ordered.oldest()
+		}
+		return ordered.newest()
+	case "min", "max", "between":
+		sorted, ok := store.(sortedStore[k, v])
+		if !ok {
+			panic("safemap: " + op.op + " requires a SafeSortedMap")
+		}
+		switch op.op {
+		case "min":
+			return sorted.min()
+		case "max":
+			return sorted.max()
+		}
+		return sorted.between(op.key, op.to)
+	case "keyOf":
+		key, ok := store.(inverseStore[k, v]).keyOf(op.value)
+		return result[k, v]{key: key, value: op.value, ok: ok}
+	case "namespace":
+		store.(namespacedStore[k, v]).visitIn(op.key, op.visit)
+		return result[k, v]{}
+	case "namespaces":
+		store.(namespacedStore[k, v]).visitNamespaces(op.visit)
+		return result[k, v]{}
+	}
+	return result[k, v]{}
+}
+
+// keys returns an iterator over the keys of the backend.
+func (c *core[k, v]) keys() iter.Seq[k] {
+	return func(yield func(k) bool) {
+		for key := range c.store.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// isReadOp reports whether the operation leaves the data untouched,
+// such operations may run outside the worker when read concurrency is enabled.
+func isReadOp(op string) bool {
+	switch op {
+	case "get", "lookup", "exist", "getMap", "getLen", "keysSlice", "valuesSlice", "random", "getMany", "forEach", "equal", "oldest", "newest", "min", "max", "between", "keyOf", "namespace", "namespaces":
+		return true
+	}
+	return false
+}
+
+// zeroReply returns the reply of an operation on a closed SafeMap,
+// which behaves as an empty map that ignores every write.
+func zeroReply[k comparable, v any](op string) result[k, v] {
+	switch op {
+	case "getMap", "getMany":
+		return result[k, v]{items: map[k]v{}}
+	}
+	return result[k, v]{}
+}
+
+// send delivers the operation to the worker and waits for its reply.
+// When read concurrency is enabled, read operations are applied directly under the read lock instead.
+// Once the SafeMap is closed, the operation is not applied and the reply of an empty map is returned.
+func (c *core[k, v]) send(op operation[k, v]) result[k, v] {
+	reply, err := c.sendCtx(context.Background(), op)
+	if err != nil {
+		return zeroReply[k, v](op.op)
+	}
+	return reply
+}
+
+// sendCtx delivers the operation to the SafeMap, reporting it to the hooks of WithHooks and WithSlowOpThreshold,
+// and recording its latency once a Collector was created.
+func (c *core[k, v]) sendCtx(ctx context.Context, op operation[k, v]) (result[k, v], error) {
+	l, hooks, slow := c.latencies.Load(), c.cfg.hooks, c.cfg.onSlowOp
+	if l == nil && hooks == nil && slow == nil {
+		return c.deliver(ctx, op)
+	}
+
+	var info OpInfo
+	if hooks != nil {
+		info = c.opInfo(op)
+		ctx = hooks.OnOpStart(ctx, info)
+	}
+	start := time.Now()
+	reply, err := c.deliver(ctx, op)
+	elapsed := time.Since(start)
+	if l != nil {
+		l.observe(op.op, elapsed)
+	}
+	if slow != nil && elapsed > c.cfg.slowOpThreshold {
+		slow(op.op, elapsed)
+	}
+	if hooks != nil {
+		hooks.OnOpEnd(ctx, info, err)
+	}
+	return reply, err
+}
+
+// deliver is like send but gives up waiting once ctx is done, returning the context error.
+// It returns ErrClosed if the SafeMap is closed before the operation is accepted.
+// If the operation was already accepted by the worker when ctx is done, it is still applied.
+func (c *core[k, v]) deliver(ctx context.Context, op operation[k, v]) (result[k, v], error) {
+	if err := ctx.Err(); err != nil {
+		return result[k, v]{}, err
+	}
+
+	if c.cfg.snapshotReads && c.bypass(op.op) {
+		return c.applySnapshot(op)
+	}
+
+	if c.readSem != nil && c.bypass(op.op) {
+		return c.applyShared(ctx, op)
+	}
+
+	if c.cfg.mutex {
+		return c.applyDirect(op, false)
+	}
+
+	// the reply channel is buffered so the worker never blocks on a caller that gave up
+	op.replyChan = c.replies.Get().(chan result[k, v])
+	if err := c.enqueue(ctx, op); err != nil {
+		c.replies.Put(op.replyChan)
+		return result[k, v]{}, err
+	}
+
+	return c.await(ctx, op.replyChan)
+}
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "exist",
-		key:       key,
-		replyChan: replyChan,
+// enqueue hands op over to the worker, waiting until the worker or the queue accepts it.
+// The operation counts as pending from then on until the worker takes it, and the time spent waiting is recorded.
+func (c *core[k, v]) enqueue(ctx context.Context, op operation[k, v]) error {
+	c.queued.Add(1)
+	select {
+	case c.opChan <- op:
+		return nil
+	default:
 	}
 
-	exist := <-replyChan
-	return exist.(bool)
+	start := time.Now()
+	defer func() {
+		c.blocked.Add(int64(time.Since(start)))
+	}()
+	select {
+	case c.opChan <- op:
+		return nil
+	case <-c.done:
+		c.queued.Add(-1)
+		return ErrClosed
+	case <-ctx.Done():
+		c.queued.Add(-1)
+		return ctx.Err()
+	}
+}
+
+// await waits for the reply of an operation accepted by the worker and re-raises a panic it recovered.
+// It returns ErrClosed if the SafeMap is closed while the operation is still queued,
+// and the context error if ctx is done first.
+func (c *core[k, v]) await(ctx context.Context, replyChan chan result[k, v]) (result[k, v], error) {
+	var reply result[k, v]
+	select {
+	case reply = <-replyChan:
+	case <-c.done:
+		select {
+		case reply = <-replyChan:
+		default:
+			// the worker stopped before applying the queued operation
+			return result[k, v]{}, ErrClosed
+		}
+	case <-ctx.Done():
+		// the worker still replies on the channel, so it is left to the garbage collector instead of the pool
+		return result[k, v]{}, ctx.Err()
+	}
+
+	c.replies.Put(replyChan)
+	if reply.panicked != nil {
+		panic(reply.panicked)
+	}
+	return reply, nil
+}
+
+// trySend is like send but fails fast instead of blocking when the operation cannot be accepted immediately,
+// because the worker is busy, all reader slots are taken or the SafeMap is closed. The ok result reports whether it was applied.
+func (c *core[k, v]) trySend(op operation[k, v]) (reply result[k, v], ok bool) {
+	select {
+	case <-c.done:
+		return reply, false
+	default:
+	}
+
+	if c.cfg.snapshotReads && c.bypass(op.op) {
+		reply, err := c.applySnapshot(op)
+		return reply, err == nil
+	}
+
+	if c.readSem != nil && c.bypass(op.op) {
+		select {
+		case c.readSem <- struct{}{}:
+		default:
+			return reply, false
+		}
+		defer func() { <-c.readSem }()
+		if !c.mu.TryRLock() {
+			return reply, false
+		}
+		defer c.mu.RUnlock()
+		select {
+		case <-c.done:
+			return reply, false
+		default:
+		}
+		return c.apply(op), true
+	}
+
+	if c.cfg.mutex {
+		reply, err := c.applyDirect(op, true)
+		return reply, err == nil
+	}
+
+	op.replyChan = c.replies.Get().(chan result[k, v])
+	c.queued.Add(1)
+	select {
+	case c.opChan <- op:
+	default:
+		c.queued.Add(-1)
+		c.replies.Put(op.replyChan)
+		return reply, false
+	}
+
+	reply, err := c.await(context.Background(), op.replyChan)
+	return reply, err == nil
+}
+
+// sendAsync delivers a write operation to the worker without waiting for it to be applied.
+// It only blocks until the worker or the queue accepts the operation, and drops it once the SafeMap is closed.
+// With WithMutex there is no worker and the operation is applied directly.
+func (c *core[k, v]) sendAsync(op operation[k, v]) {
+	if c.cfg.mutex {
+		c.applyDirect(op, false)
+		return
+	}
+
+	c.enqueue(context.Background(), op)
+}
+
+// Set sets the value for the given key in the SafeMap.
+func (s *SafeMap[k, v]) Set(key k, val v) {
+	s.send(operation[k, v]{
+		op:    "set",
+		key:   key,
+		value: val,
+	})
+}
+
+// Get retrieves the value for the given key from the SafeMap.
+// A missing key returns the zero value, or its default value with WithDefault.
+func (s *SafeMap[k, v]) Get(key k) (val v) {
+	reply := s.send(operation[k, v]{
+		op:  "get",
+		key: key,
+	})
+	return s.valueOf(key, reply)
+}
+
+// valueOf returns the value of the reply to a get operation, the default value of the key if it was missing.
+func (c *core[k, v]) valueOf(key k, reply result[k, v]) v {
+	if !reply.ok && c.cfg.defaultValue != nil {
+		return c.cfg.defaultValue(key)
+	}
+	return reply.value
+}
+
+// Lookup retrieves the value for the given key from the SafeMap
+// and reports whether the key was present, so a stored zero value can be told apart from a missing key.
+func (s *SafeMap[k, v]) Lookup(key k) (val v, ok bool) {
+	reply := s.send(operation[k, v]{
+		op:  "lookup",
+		key: key,
+	})
+	return reply.value, reply.ok
+}
+
+// Delete removes the key-value pair for the given key from the SafeMap.
+func (s *SafeMap[k, v]) Delete(key k) {
+	s.send(operation[k, v]{
+		op:  "delete",
+		key: key,
+	})
+}
+
+// Exist checks if the given key exists in the SafeMap.
+func (s *SafeMap[k, v]) Exist(key k) bool {
+	return s.send(operation[k, v]{
+		op:  "exist",
+		key: key,
+	}).ok
 }
 
 // Keys returns a slice of all keys in the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
 // example
 //
 //	m := NewSafeMap[int, int]()
@@ -138,23 +1446,10 @@ func (s *SafeMap[k, v]) Exist(key k) bool {
 //		fmt.Println(key)
 //	}
 func (s *SafeMap[k, v]) Keys() iter.Seq[k] {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
-	}
-
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "getMap",
-		replyChan: replyChan,
-	}
-
-	m := <-replyChan
-
-	return maps.Keys(m.(map[k]v))
+	return s.entries().Keys()
 }
 
 // All returns a slice of all key-value pairs in the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
 // example
 //
 //	m := NewSafeMap[int, int]()
@@ -163,51 +1458,594 @@ func (s *SafeMap[k, v]) Keys() iter.Seq[k] {
 //		fmt.Println(key, value)
 //	}
 func (s *SafeMap[k, v]) All() iter.Seq2[k, v] {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
-	}
+	return s.entries().All()
+}
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "getMap",
-		replyChan: replyChan,
-	}
+// Values returns an iterator over all values in the SafeMap.
+// example
+//
+//	m := NewSafeMap[int, int]()
+//	m.Set(1, 2)
+//	for value := range m.Values() {
+//		fmt.Println(value)
+//	}
+func (s *SafeMap[k, v]) Values() iter.Seq[v] {
+	return s.entries().Values()
+}
 
-	m := <-replyChan
+// KeysSlice returns the keys of the SafeMap in a new slice, collected in a single operation.
+// Unlike collecting Keys, it doesn't copy the values, nor the keys twice.
+func (s *SafeMap[k, v]) KeysSlice() []k {
+	return s.send(operation[k, v]{op: "keysSlice"}).order
+}
 
-	return maps.All(m.(map[k]v))
+// ValuesSlice returns the values of the SafeMap in a new slice, collected in a single operation.
+// Unlike collecting Values, it doesn't copy the keys, nor the values twice.
+func (s *SafeMap[k, v]) ValuesSlice() []v {
+	return s.send(operation[k, v]{op: "valuesSlice"}).values
+}
+
+// KeysSorted returns an iterator over the keys of the SafeMap sorted with cmp, which returns a negative number
+// when a < b, a positive number when a > b and zero when a == b. A nil cmp orders numbers and strings by value,
+// and any other key by its formatted representation. The keys are collected in a single operation and sorted
+// in the calling goroutine.
+// example
+//
+//	for key := range m.KeysSorted(nil) {
+//		fmt.Println(key, m.Get(key))
+//	}
+func (s *SafeMap[k, v]) KeysSorted(cmp func(a, b k) int) iter.Seq[k] {
+	if cmp == nil {
+		cmp = compareKeys[k]
+	}
+	keys := make([]k, 0)
+	s.send(operation[k, v]{
+		op: "forEach",
+		visit: func(key k, _ v) bool {
+			keys = append(keys, key)
+			return true
+		},
+	})
+	slices.SortFunc(keys, cmp)
+	return slices.Values(keys)
+}
+
+// entries returns a copy of the entries of the SafeMap, to iterate over.
+func (s *SafeMap[k, v]) entries() *Snapshot[k, v] {
+	reply := s.send(operation[k, v]{op: "getMap"})
+	return &Snapshot[k, v]{items: reply.items, order: reply.order}
 }
 
 // Length returns the number of key-value pairs in the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
 func (s *SafeMap[k, v]) Length() int {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+	return s.send(operation[k, v]{op: "getLen"}).n
+}
+
+// SizeBytes returns the approximate memory held by the entries of the SafeMap, as the sum of their sizes
+// estimated by the function of WithSizeEstimator or WithMaxBytes. The total is maintained by the worker
+// as entries are written and removed, so SizeBytes returns it without visiting the entries.
+// It returns 0 if the SafeMap was created without WithSizeEstimator or WithMaxBytes, whose entries are not sized.
+// example
+//
+//	if m.SizeBytes() > budget {
+//		return ErrOverBudget
+//	}
+func (s *SafeMap[k, v]) SizeBytes() int64 {
+	return int64(s.send(operation[k, v]{op: "sizeBytes"}).n)
+}
+
+// GetMap returns a copy of the internal map of the SafeMap.
+func (s *SafeMap[k, v]) GetMap() map[k]v {
+	return s.send(operation[k, v]{op: "getMap"}).items
+}
+
+// ContentHash returns a hash over all key-value pairs in the SafeMap.
+// The hash is computed from a snapshot and does not depend on insertion order,
+// so two maps holding the same entries always produce the same hash.
+// Keys and values are hashed through their Go-syntax representation, which keeps the
+// result stable across processes for plain data types, but not for pointers, channels
+// or funcs whose representation is an address.
+func (s *SafeMap[k, v]) ContentHash() uint64 {
+	items := s.send(operation[k, v]{op: "getMap"}).items
+
+	var sum uint64
+	h := fnv.New64a()
+	for key, val := range items {
+		h.Reset()
+		fmt.Fprintf(h, "%#v\x00%#v", key, val)
+		// entries are combined with addition so the result is order independent,
+		// mixing each entry first keeps similar entries from cancelling out.
+		sum += mixHash(h.Sum64())
+	}
+
+	return mixHash(sum ^ uint64(len(items)))
+}
+
+// mixHash is the splitmix64 finalizer, used to spread the bits of an entry hash.
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// GetOrSet returns the existing value for the given key if present.
+// Otherwise, it stores the given value and returns it. The loaded result is true if the value was loaded, false if stored.
+// The check and the store happen atomically in the worker goroutine.
+func (s *SafeMap[k, v]) GetOrSet(key k, val v) (actual v, loaded bool) {
+	reply := s.send(operation[k, v]{
+		op:    "getOrSet",
+		key:   key,
+		value: val,
+	})
+	return reply.value, reply.ok
+}
+
+// GetAndDelete removes the key from the SafeMap and returns the value it held, if any.
+// The ok result reports whether the key was present. Both steps happen atomically in the worker goroutine.
+func (s *SafeMap[k, v]) GetAndDelete(key k) (val v, ok bool) {
+	reply := s.send(operation[k, v]{
+		op:  "getAndDelete",
+		key: key,
+	})
+	return reply.value, reply.ok
+}
+
+// Swap stores the value for the given key and returns the previous value, if any.
+// The loaded result reports whether the key was present before the swap.
+func (s *SafeMap[k, v]) Swap(key k, val v) (previous v, loaded bool) {
+	reply := s.send(operation[k, v]{
+		op:    "swap",
+		key:   key,
+		value: val,
+	})
+	return reply.value, reply.ok
+}
+
+// CompareAndSwap swaps the old and new values for the given key if the value stored in the SafeMap is equal to old.
+// The swapped result reports whether the swap was performed.
+// The value type must be comparable, otherwise the comparison panics; use CompareAndSwapFunc for other types.
+func (s *SafeMap[k, v]) CompareAndSwap(key k, old, new v) (swapped bool) {
+	return s.CompareAndSwapFunc(key, old, new, equalAny[v])
+}
+
+// CompareAndSwapFunc swaps the old and new values for the given key if equal reports that the stored value matches old.
+// The equal function runs inside the worker goroutine and must not call methods of the same SafeMap.
+func (s *SafeMap[k, v]) CompareAndSwapFunc(key k, old, new v, equal func(a, b v) bool) (swapped bool) {
+	reply := s.send(operation[k, v]{
+		op:    "compareAndSwap",
+		key:   key,
+		value: new,
+		old:   old,
+		equal: equal,
+	})
+	return reply.ok
+}
+
+// equalAny compares two values through interfaces, it panics when the dynamic type is not comparable.
+func equalAny[v any](a, b v) bool {
+	return any(a) == any(b)
+}
+
+// CompareAndDelete deletes the entry for the given key if its value is equal to old.
+// The deleted result reports whether the entry was removed.
+// The value type must be comparable, otherwise the comparison panics; use CompareAndDeleteFunc for other types.
+func (s *SafeMap[k, v]) CompareAndDelete(key k, old v) (deleted bool) {
+	return s.CompareAndDeleteFunc(key, old, equalAny[v])
+}
+
+// CompareAndDeleteFunc deletes the entry for the given key if equal reports that the stored value matches old.
+// The equal function runs inside the worker goroutine and must not call methods of the same SafeMap.
+func (s *SafeMap[k, v]) CompareAndDeleteFunc(key k, old v, equal func(a, b v) bool) (deleted bool) {
+	reply := s.send(operation[k, v]{
+		op:    "compareAndDelete",
+		key:   key,
+		old:   old,
+		equal: equal,
+	})
+	return reply.ok
+}
+
+// Clear removes all entries from the SafeMap in a single operation and returns the number of entries removed.
+func (s *SafeMap[k, v]) Clear() int {
+	return s.send(operation[k, v]{op: "clear"}).n
+}
+
+// Update atomically computes a new value for the given key.
+// The fn function receives the current value and whether the key exists, and returns the new value and whether to keep it.
+// When keep is false the key is deleted. Update returns the resulting value and whether the key is present afterwards.
+// The fn function runs inside the worker goroutine, so it must be fast and must not call methods of the same SafeMap.
+// example
+//
+//	m := NewSafeMap[string, int]()
+//	m.Update("hits", func(old int, exists bool) (int, bool) {
+//		return old + 1, true
+//	})
+func (s *SafeMap[k, v]) Update(key k, fn func(old v, exists bool) (new v, keep bool)) (val v, ok bool) {
+	reply := s.send(operation[k, v]{
+		op:     "update",
+		key:    key,
+		update: fn,
+	})
+	return reply.value, reply.ok
+}
+
+// Upsert inserts the value for the given key if it is absent,
+// otherwise it stores the result of merging the existing value with the incoming one. It returns the stored value.
+// The merge function runs inside the worker goroutine and must not call methods of the same SafeMap.
+// example
+//
+//	m := NewSafeMap[string, int]()
+//	m.Upsert("requests", 1, func(existing, incoming int) int {
+//		return existing + incoming
+//	})
+func (s *SafeMap[k, v]) Upsert(key k, val v, merge func(existing, incoming v) v) v {
+	reply := s.send(operation[k, v]{
+		op:    "upsert",
+		key:   key,
+		value: val,
+		merge: merge,
+	})
+	return reply.value
+}
+
+// SetIfAbsent sets the value for the given key only if the key is not present in the SafeMap.
+// It reports whether the value was set.
+func (s *SafeMap[k, v]) SetIfAbsent(key k, val v) bool {
+	reply := s.send(operation[k, v]{
+		op:    "setIfAbsent",
+		key:   key,
+		value: val,
+	})
+	return reply.ok
+}
+
+// SetIfPresent sets the value for the given key only if the key is already present in the SafeMap.
+// It reports whether the value was set.
+func (s *SafeMap[k, v]) SetIfPresent(key k, val v) bool {
+	reply := s.send(operation[k, v]{
+		op:    "setIfPresent",
+		key:   key,
+		value: val,
+	})
+	return reply.ok
+}
+
+// Pop removes an arbitrary entry from the SafeMap and returns it.
+// The ok result is false if the SafeMap is empty. Which entry is removed is unspecified.
+func (s *SafeMap[k, v]) Pop() (key k, val v, ok bool) {
+	reply := s.send(operation[k, v]{op: "pop"})
+	return reply.key, reply.value, reply.ok
+}
+
+// GetRandom returns an entry of the SafeMap chosen uniformly at random, without copying the entries.
+// The ok result is false if the SafeMap is empty. Since a map can't be indexed, choosing the entry
+// visits half of the entries on average, in a single operation.
+// example
+//
+//	if key, _, ok := m.GetRandom(); ok {
+//		m.Delete(key)
+//	}
+func (s *SafeMap[k, v]) GetRandom() (key k, val v, ok bool) {
+	reply := s.send(operation[k, v]{op: "random"})
+	return reply.key, reply.value, reply.ok
+}
+
+// SetMany sets all key-value pairs of items in the SafeMap in a single operation.
+// Other operations observe either none or all of the writes.
+func (s *SafeMap[k, v]) SetMany(items map[k]v) {
+	s.send(operation[k, v]{
+		op:    "setMany",
+		items: items,
+	})
+}
+
+// GetMany retrieves the values for the given keys from the SafeMap in a single operation.
+// The returned map only contains the keys that are present, all values are read from the same consistent view.
+func (s *SafeMap[k, v]) GetMany(keys ...k) map[k]v {
+	return s.send(operation[k, v]{
+		op:   "getMany",
+		keys: keys,
+	}).items
+}
+
+// DeleteMany removes the given keys from the SafeMap in a single operation.
+// It returns the number of keys that were present and deleted.
+func (s *SafeMap[k, v]) DeleteMany(keys ...k) int {
+	return s.send(operation[k, v]{
+		op:   "deleteMany",
+		keys: keys,
+	}).n
+}
+
+// Clone returns a new SafeMap holding a snapshot of the entries of the SafeMap.
+// The clone has its own worker goroutine and the same options, changes to either map are not visible in the other.
+// The clone always stores its entries in a plain map, even if the SafeMap uses a custom Backend,
+// except with WithInsertionOrder or for a specialised map such as SafeSortedMap, whose clone keeps the same storage.
+// Values are copied as is, so reference types such as slices or pointers are shared between both maps.
+func (s *SafeMap[k, v]) Clone() *SafeMap[k, v] {
+	reply := s.send(operation[k, v]{op: "clone"})
+
+	cfg := s.cfg
+	cfg.backend = nil
+	var store Backend[k, v] = mapBackend[k, v](reply.items)
+	if cfg.insertionOrder || cfg.newStore != nil {
+		var ordered Backend[k, v] = newOrderedBackend[k, v](len(reply.order))
+		if cfg.newStore != nil {
+			ordered = cfg.newStore(len(reply.order))
+		}
+		for key, val := range (&Snapshot[k, v]{items: reply.items, order: reply.order}).All() {
+			ordered.Set(key, val)
+		}
+		store = ordered
+	}
+	// the state is filled before the goroutines of the clone start
+	clone := newCore(cfg, store)
+	// with copy-on-write, items is the current version of the SafeMap, not a copy
+	clone.shared.Store(cfg.copyOnWrite)
+	for _, exp := range reply.expiries {
+		clone.expiries.set(exp.key, exp.ttl, exp.deadline)
+		clone.hasExpiries.Store(true)
+	}
+	if reply.policy != nil {
+		clone.policy = reply.policy
+	} else if clone.policy != nil {
+		// a custom policy starts over from the entries of the clone
+		for key := range reply.items {
+			clone.policy.RecordInsert(key)
+		}
+	}
+	if cfg.sizeOf != nil {
+		clone.sizes = make(map[k]int, len(reply.items))
+		for key, val := range reply.items {
+			size := cfg.sizeOf(key, val)
+			clone.sizes[key] = size
+			clone.bytes += size
+		}
 	}
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "getLen",
-		replyChan: replyChan,
+	sm := &SafeMap[k, v]{}
+	sm.start(clone)
+	return sm
+}
+
+// Merge copies all entries of src into the SafeMap, overwriting existing keys.
+// The entries are applied in a single operation, so concurrent writers cannot interleave with the merge.
+func (s *SafeMap[k, v]) Merge(src map[k]v) {
+	s.SetMany(src)
+}
+
+// MergeSafeMap copies all entries of other into the SafeMap, overwriting existing keys.
+// A snapshot of other is taken first and then applied to the SafeMap in a single operation.
+func (s *SafeMap[k, v]) MergeSafeMap(other *SafeMap[k, v]) {
+	if s == other {
+		return
 	}
 
-	length := <-replyChan
-	return length.(int)
+	s.SetMany(other.GetMap())
 }
 
-// GetMap returns a copy of the internal map of the SafeMap.
-// If the SafeMap was not initialized using NewSafeMap, it panics.
-func (s *SafeMap[k, v]) GetMap() map[k]v {
-	if s.opChan == nil {
-		panic("safemap can be only accessed with NewSafeMap")
+// DeleteFunc deletes every entry for which del returns true and returns the number of deleted entries.
+// It mirrors maps.DeleteFunc and runs atomically inside the worker goroutine,
+// so del must be fast and must not call methods of the same SafeMap.
+// example
+//
+//	m := NewSafeMap[string, time.Time]()
+//	m.DeleteFunc(func(id string, lastSeen time.Time) bool {
+//		return time.Since(lastSeen) > time.Hour
+//	})
+func (s *SafeMap[k, v]) DeleteFunc(del func(key k, val v) bool) int {
+	return s.send(operation[k, v]{
+		op:    "deleteFunc",
+		match: del,
+	}).n
+}
+
+// TransformValues replaces the value of every entry with the result of fn.
+// All values are rewritten atomically inside the worker goroutine, no other operation observes a partially transformed map.
+// The fn function must be fast and must not call methods of the same SafeMap.
+func (s *SafeMap[k, v]) TransformValues(fn func(key k, val v) v) {
+	s.send(operation[k, v]{
+		op:        "transformValues",
+		transform: fn,
+	})
+}
+
+// ForEach calls fn for each entry of the SafeMap, stopping when fn returns false.
+// The entries visited are those of a consistent Snapshot taken when ForEach is called, and fn runs in the calling
+// goroutine: it may read and write the SafeMap, but its writes are not visited. See ForEachLocked to hold back
+// the writes of other goroutines while visiting the entries.
+// example
+//
+//	m.ForEach(func(key string, val int) bool {
+//		fmt.Println(key, val)
+//		return key != "last"
+//	})
+func (s *SafeMap[k, v]) ForEach(fn func(key k, val v) bool) {
+	for key, val := range s.Snapshot().All() {
+		if !fn(key, val) {
+			return
+		}
 	}
+}
+
+// ForEachLocked calls fn for each entry of the SafeMap within a single operation, stopping when fn returns false.
+// Like Reduce, it visits the entries in place, without copying them, and no write is applied while fn runs,
+// for callers needing strict consistency. It is applied as a write, even WithSnapshotReads or WithReadConcurrency,
+// so the writes and the reads wait meanwhile: fn must be fast and must not call methods of the same SafeMap.
+// example
+//
+//	m.ForEachLocked(func(key string, val int) bool {
+//		total += val
+//		return true
+//	})
+func (s *SafeMap[k, v]) ForEachLocked(fn func(key k, val v) bool) {
+	s.send(operation[k, v]{
+		op:    "forEachLocked",
+		visit: fn,
+	})
+}
+
+// Reduce folds all entries of the SafeMap into a single value, starting from seed.
+// The entries are visited inside the worker goroutine, so the result is computed over a consistent view
+// without copying the map. The fn function must be fast and must not call methods of the same SafeMap.
+// example
+//
+//	m := NewSafeMap[string, int]()
+//	total := Reduce(m, 0, func(sum int, key string, val int) int {
+//		return sum + val
+//	})
+func Reduce[k comparable, v any, T any](s *SafeMap[k, v], seed T, fn func(acc T, key k, val v) T) T {
+	acc := seed
+	s.send(operation[k, v]{
+		op: "forEach",
+		visit: func(key k, val v) bool {
+			acc = fn(acc, key, val)
+			return true
+		},
+	})
+	return acc
+}
 
-	replyChan := make(chan any)
-	s.opChan <- operation[k, v]{
-		op:        "getMap",
-		replyChan: replyChan,
+// Equal reports whether the SafeMap and other hold the same keys with equal values.
+// Values are compared with eq, when eq is nil they are compared with == and the value type must be comparable.
+// A snapshot of other is compared against the current entries of the SafeMap in a single operation.
+func (s *SafeMap[k, v]) Equal(other *SafeMap[k, v], eq func(a, b v) bool) bool {
+	if s == other {
+		return true
 	}
+	if eq == nil {
+		eq = equalAny[v]
+	}
+
+	return s.send(operation[k, v]{
+		op:    "equal",
+		items: other.GetMap(),
+		equal: eq,
+	}).ok
+}
+
+// String implements fmt.Stringer. It prints the length of the SafeMap followed by at most 10 entries,
+// picked in ascending key order for keys of an ordered kind (numbers and strings) and by their formatted key otherwise.
+// example
+//
+//	SafeMap[len=12]{a:1 b:2 c:3 d:4 e:5 f:6 g:7 h:8 i:9 j:10 ...}
+func (s *SafeMap[k, v]) String() string {
+	type entry struct {
+		key k
+		val v
+	}
+
+	var (
+		n     int
+		first []entry
+	)
+	s.send(operation[k, v]{
+		op: "forEach",
+		visit: func(key k, val v) bool {
+			n++
+			i, _ := slices.BinarySearchFunc(first, key, func(e entry, key k) int {
+				return compareKeys(e.key, key)
+			})
+			if i < stringMaxEntries {
+				first = slices.Insert(first, i, entry{key: key, val: val})
+				first = first[:min(len(first), stringMaxEntries)]
+			}
+			return true
+		},
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SafeMap[len=%d]{", n)
+	for i, e := range first {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v:%v", e.key, e.val)
+	}
+	if n > len(first) {
+		b.WriteString(" ...")
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// compareKeys orders two keys, numbers and strings are compared by value
+// and every other kind falls back to comparing the formatted keys.
+func compareKeys[k comparable](a, b k) int {
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if ra.IsValid() && rb.IsValid() && ra.Kind() == rb.Kind() {
+		switch ra.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return cmp.Compare(ra.Int(), rb.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return cmp.Compare(ra.Uint(), rb.Uint())
+		case reflect.Float32, reflect.Float64:
+			return cmp.Compare(ra.Float(), rb.Float())
+		case reflect.String:
+			return strings.Compare(ra.String(), rb.String())
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// Close stops the worker goroutine of the SafeMap and releases its entries.
+// After Close, reads behave as on an empty map and writes are ignored.
+// Close returns ErrClosed if the SafeMap was already closed.
+// With WithAutoSnapshot, the entries are saved a last time and the error of saving them is returned.
+func (s *SafeMap[k, v]) Close() error {
+	return s.load().shutdown()
+}
+
+// shutdown closes the SafeMap, saving a last snapshot first if it was created WithAutoSnapshot.
+func (c *core[k, v]) shutdown() error {
+	if c.cfg.snapshotPath != "" {
+		return c.closeSaving()
+	}
+	return c.close()
+}
+
+// close signals the worker goroutine to stop, it returns ErrClosed if it was already signalled.
+func (c *core[k, v]) close() error {
+	err := ErrClosed
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = nil
+
+		if c.cfg.mutex {
+			// without a worker, the entries are released here
+			c.mu.Lock()
+			c.release()
+			c.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// TryGet retrieves the value for the given key without blocking on a busy worker.
+// The found result reports whether the key exists, the ok result is false if the operation
+// could not be accepted immediately or the SafeMap is closed, in which case found is false as well.
+func (s *SafeMap[k, v]) TryGet(key k) (val v, found bool, ok bool) {
+	reply, ok := s.trySend(operation[k, v]{
+		op:  "lookup",
+		key: key,
+	})
+	if !ok {
+		return val, false, false
+	}
+	return reply.value, reply.ok, true
+}
 
-	items := <-replyChan
-	return items.(map[k]v)
+// TrySet sets the value for the given key without blocking on a busy worker.
+// It reports whether the value was stored, false means the operation could not be accepted immediately or the SafeMap is closed.
+func (s *SafeMap[k, v]) TrySet(key k, val v) bool {
+	_, ok := s.trySend(operation[k, v]{
+		op:    "set",
+		key:   key,
+		value: val,
+	})
+	return ok
 }
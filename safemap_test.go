@@ -1,8 +1,12 @@
 package safemap
 
 import (
+	"fmt"
+	"runtime"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,6 +61,64 @@ func TestSafeMap_All(t *testing.T) {
 	}
 }
 
+func TestSafeMap_Values(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i*10)
+	}
+
+	var valuesSlice []int
+	for value := range m.Values() {
+		valuesSlice = append(valuesSlice, value)
+	}
+	assert.Len(t, valuesSlice, 10)
+	for i := range 10 {
+		assert.Contains(t, valuesSlice, i*10)
+	}
+}
+
+func TestSafeMap_KeysSlice(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	defer m.Close()
+	assert.Empty(t, m.KeysSlice())
+	for i := range 10 {
+		m.Set(i, i*10)
+	}
+
+	keys := m.KeysSlice()
+	assert.Len(t, keys, 10)
+	slices.Sort(keys)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, keys)
+
+	values := m.ValuesSlice()
+	slices.Sort(values)
+	assert.Equal(t, []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90}, values)
+
+	ordered := NewSafeMap(WithInsertionOrder[string, int]())
+	defer ordered.Close()
+	ordered.Set("b", 1)
+	ordered.Set("a", 2)
+	assert.Equal(t, []string{"b", "a"}, ordered.KeysSlice())
+	assert.Equal(t, []int{1, 2}, ordered.ValuesSlice())
+}
+
+func TestSafeMap_KeysSorted(t *testing.T) {
+	m := NewSafeMap[int, string]()
+	for _, i := range []int{3, -1, 20, 0, 7} {
+		m.Set(i, fmt.Sprint(i))
+	}
+
+	assert.Equal(t, []int{-1, 0, 3, 7, 20}, slices.Collect(m.KeysSorted(nil)))
+	assert.Equal(t, []int{20, 7, 3, 0, -1}, slices.Collect(m.KeysSorted(func(a, b int) int { return b - a })))
+
+	type point struct{ x, y int }
+	points := NewSafeMap[point, bool]()
+	points.Set(point{2, 1}, true)
+	points.Set(point{1, 2}, true)
+	assert.Equal(t, []point{{1, 2}, {2, 1}}, slices.Collect(points.KeysSorted(nil)), "other keys are ordered by their formatted representation")
+}
+
 func TestSafeMap_Get(t *testing.T) {
 	m := NewSafeMap[int, int]()
 
@@ -70,6 +132,25 @@ func TestSafeMap_Get(t *testing.T) {
 	}
 }
 
+func TestSafeMap_Lookup(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	m.Set(1, 0)
+	m.Set(2, 2)
+
+	val, ok := m.Lookup(1)
+	assert.True(t, ok)
+	assert.Equal(t, 0, val)
+
+	val, ok = m.Lookup(2)
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	val, ok = m.Lookup(3)
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+}
+
 func TestSafeMap_Delete(t *testing.T) {
 	m := NewSafeMap[int, int]()
 
@@ -97,6 +178,46 @@ func TestSafeMap_Length(t *testing.T) {
 	assert.Equal(t, 10, m.Length())
 }
 
+func TestSafeMap_SizeBytes(t *testing.T) {
+	size := func(key, val string) int { return len(key) + len(val) }
+	// without an estimator the entries are not sized
+	plain := NewSafeMap[string, string]()
+	defer plain.Close()
+	plain.Set("a", "1234")
+	assert.Zero(t, plain.SizeBytes())
+
+	m := NewSafeMap(WithSizeEstimator(size))
+	defer m.Close()
+	m.Set("a", "1234")
+	assert.Equal(t, int64(5), m.SizeBytes())
+	m.Set("b", "12")
+	m.Set("a", "1")
+	assert.Equal(t, int64(5), m.SizeBytes())
+	m.Delete("b")
+	assert.Equal(t, int64(2), m.SizeBytes())
+
+	// the clone keeps the estimator
+	clone := m.Clone()
+	defer clone.Close()
+	clone.Set("c", "123")
+	assert.Equal(t, int64(6), clone.SizeBytes())
+
+	m.Clear()
+	assert.Zero(t, m.SizeBytes())
+
+	// with WithMaxBytes the total of the bound is returned
+	bounded := NewSafeMap(WithMaxBytes(10, size))
+	defer bounded.Close()
+	bounded.Set("a", "1234")
+	bounded.Set("b", "1234")
+	bounded.Set("c", "1234")
+	assert.Equal(t, int64(10), bounded.SizeBytes())
+
+	closed := NewSafeMap(WithSizeEstimator(size))
+	assert.NoError(t, closed.Close())
+	assert.Zero(t, closed.SizeBytes())
+}
+
 func TestSafeMap_GetMap(t *testing.T) {
 	m := NewSafeMap[int, int]()
 
@@ -107,18 +228,881 @@ func TestSafeMap_GetMap(t *testing.T) {
 	assert.Equal(t, map[int]int{0: 0, 1: 1, 2: 2}, m.GetMap())
 }
 
-func TestSafeMap_Panic(t *testing.T) {
+func TestSafeMap_ContentHash(t *testing.T) {
+	m1 := NewSafeMap[string, int]()
+	m2 := NewSafeMap[string, int]()
+
+	for i := range 10 {
+		m1.Set(fmt.Sprint(i), i)
+	}
+	for i := 9; i >= 0; i-- {
+		m2.Set(fmt.Sprint(i), i)
+	}
+
+	assert.Equal(t, m1.ContentHash(), m2.ContentHash())
+
+	m2.Set("5", 50)
+	assert.NotEqual(t, m1.ContentHash(), m2.ContentHash())
+
+	m2.Set("5", 5)
+	assert.Equal(t, m1.ContentHash(), m2.ContentHash())
+
+	assert.NotEqual(t, NewSafeMap[string, int]().ContentHash(), m1.ContentHash())
+}
+
+func TestSafeMap_GetOrSet(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	actual, loaded := m.GetOrSet("a", 1)
+	assert.False(t, loaded)
+	assert.Equal(t, 1, actual)
+
+	actual, loaded = m.GetOrSet("a", 2)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	assert.Equal(t, 1, m.Get("a"))
+
+	var wg sync.WaitGroup
+	var stored sync.Map
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, loaded := m.GetOrSet("b", i); !loaded {
+				stored.Store(i, true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	stored.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1, count)
+}
+
+func TestSafeMap_GetAndDelete(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 100 {
+		m.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	popped := make(chan int, 1000)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if val, ok := m.GetAndDelete(i); ok {
+					popped <- val
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(popped)
+
+	var values []int
+	for val := range popped {
+		values = append(values, val)
+	}
+	assert.Len(t, values, 100)
+	assert.Equal(t, 0, m.Length())
+
+	val, ok := m.GetAndDelete(1)
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+}
+
+func TestSafeMap_Swap(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	previous, loaded := m.Swap("a", 1)
+	assert.False(t, loaded)
+	assert.Equal(t, 0, previous)
+
+	previous, loaded = m.Swap("a", 2)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, previous)
+	assert.Equal(t, 2, m.Get("a"))
+}
+
+func TestSafeMap_CompareAndSwap(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	assert.False(t, m.CompareAndSwap("a", 0, 1))
+	assert.False(t, m.Exist("a"))
+
+	m.Set("a", 1)
+	assert.False(t, m.CompareAndSwap("a", 2, 3))
+	assert.Equal(t, 1, m.Get("a"))
+	assert.True(t, m.CompareAndSwap("a", 1, 3))
+	assert.Equal(t, 3, m.Get("a"))
+
+	counter := NewSafeMap[string, int]()
+	counter.Set("hits", 0)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				for {
+					cur := counter.Get("hits")
+					if counter.CompareAndSwap("hits", cur, cur+1) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000, counter.Get("hits"))
+}
+
+func TestSafeMap_CompareAndSwapFunc(t *testing.T) {
+	m := NewSafeMap[string, []int]()
+	m.Set("a", []int{1, 2})
+
+	assert.False(t, m.CompareAndSwapFunc("a", []int{1}, []int{3}, slices.Equal))
+	assert.True(t, m.CompareAndSwapFunc("a", []int{1, 2}, []int{3}, slices.Equal))
+	assert.Equal(t, []int{3}, m.Get("a"))
+
+	// comparing non comparable values panics in the caller, not in the worker
+	assert.Panics(t, func() { m.CompareAndSwap("a", []int{3}, []int{4}) })
+	m.Set("b", []int{5})
+	assert.Equal(t, []int{5}, m.Get("b"))
+}
+
+func TestSafeMap_CompareAndDelete(t *testing.T) {
+	m := NewSafeMap[string, string]()
+
+	assert.False(t, m.CompareAndDelete("lock", "owner-1"))
+
+	m.Set("lock", "owner-1")
+	assert.False(t, m.CompareAndDelete("lock", "owner-2"))
+	assert.True(t, m.Exist("lock"))
+	assert.True(t, m.CompareAndDelete("lock", "owner-1"))
+	assert.False(t, m.Exist("lock"))
+
+	s := NewSafeMap[string, []string]()
+	s.Set("a", []string{"x"})
+	assert.False(t, s.CompareAndDeleteFunc("a", []string{"y"}, slices.Equal))
+	assert.True(t, s.CompareAndDeleteFunc("a", []string{"x"}, slices.Equal))
+	assert.False(t, s.Exist("a"))
+}
+
+func TestSafeMap_Clear(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	assert.Equal(t, 10, m.Clear())
+	assert.Equal(t, 0, m.Length())
+	assert.False(t, m.Exist(1))
+	assert.Equal(t, 0, m.Clear())
+
+	m.Set(1, 1)
+	assert.Equal(t, 1, m.Length())
+}
+
+func TestSafeMap_Update(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				m.Update("hits", func(old int, exists bool) (int, bool) {
+					return old + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, m.Get("hits"))
+
+	val, ok := m.Update("hits", func(old int, exists bool) (int, bool) {
+		assert.True(t, exists)
+		return 0, old < 1000
+	})
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+	assert.False(t, m.Exist("hits"))
+
+	val, ok = m.Update("new", func(old int, exists bool) (int, bool) {
+		assert.False(t, exists)
+		return 7, true
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 7, val)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		m.Update("new", func(int, bool) (int, bool) { panic("boom") })
+	})
+	assert.Equal(t, 7, m.Get("new"))
+}
+
+func TestSafeMap_Upsert(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	sum := func(existing, incoming int) int { return existing + incoming }
+
+	assert.Equal(t, 5, m.Upsert("a", 5, sum))
+	assert.Equal(t, 8, m.Upsert("a", 3, sum))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				m.Upsert("b", 1, sum)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8, m.Get("a"))
+	assert.Equal(t, 1000, m.Get("b"))
+}
+
+func TestSafeMap_SetIfAbsent(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	assert.True(t, m.SetIfAbsent("a", 1))
+	assert.False(t, m.SetIfAbsent("a", 2))
+	assert.Equal(t, 1, m.Get("a"))
+}
+
+func TestSafeMap_SetIfPresent(t *testing.T) {
+	m := NewSafeMap[string, int]()
+
+	assert.False(t, m.SetIfPresent("a", 1))
+	assert.False(t, m.Exist("a"))
+
+	m.Set("a", 1)
+	assert.True(t, m.SetIfPresent("a", 2))
+	assert.Equal(t, 2, m.Get("a"))
+}
+
+func TestSafeMap_GetRandom(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	_, _, ok := m.GetRandom()
+	assert.False(t, ok)
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	counts := make(map[string]int)
+	for range 3000 {
+		key, val, ok := m.GetRandom()
+		assert.True(t, ok)
+		assert.Equal(t, m.Get(key), val)
+		counts[key]++
+	}
+	for key, n := range counts {
+		assert.Greater(t, n, 800, "%s is chosen uniformly", key)
+	}
+	assert.Len(t, counts, 3)
+}
+
+func TestSafeMap_Pop(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i*10)
+	}
+
+	seen := make(map[int]bool)
+	for range 10 {
+		key, val, ok := m.Pop()
+		assert.True(t, ok)
+		assert.Equal(t, key*10, val)
+		assert.False(t, seen[key])
+		seen[key] = true
+	}
+
+	_, _, ok := m.Pop()
+	assert.False(t, ok)
+	assert.Equal(t, 0, m.Length())
+}
+
+func TestSafeMap_SetMany(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	m.Set(0, -1)
+
+	items := make(map[int]int)
+	for i := range 100 {
+		items[i] = i
+	}
+	m.SetMany(items)
+
+	assert.Equal(t, 100, m.Length())
+	assert.Equal(t, items, m.GetMap())
+
+	m.SetMany(nil)
+	assert.Equal(t, 100, m.Length())
+}
+
+func TestSafeMap_GetMany(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i*10)
+	}
+
+	assert.Equal(t, map[int]int{1: 10, 3: 30, 5: 50}, m.GetMany(1, 3, 5, 42))
+	assert.Empty(t, m.GetMany())
+	assert.Empty(t, m.GetMany(100))
+}
+
+func TestSafeMap_DeleteMany(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	assert.Equal(t, 3, m.DeleteMany(1, 3, 5, 42, 3))
+	assert.Equal(t, 7, m.Length())
+	assert.False(t, m.Exist(3))
+	assert.Equal(t, 0, m.DeleteMany())
+}
+
+func TestSafeMap_Clone(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	clone := m.Clone()
+	assert.Equal(t, m.GetMap(), clone.GetMap())
+
+	clone.Set(100, 100)
+	m.Delete(0)
+
+	assert.False(t, m.Exist(100))
+	assert.True(t, clone.Exist(0))
+	assert.Equal(t, 9, m.Length())
+	assert.Equal(t, 11, clone.Length())
+}
+
+func TestSafeMap_Merge(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	m.Set("a", 1)
+	m.Set("b", 2)
+
+	m.Merge(map[string]int{"b": 20, "c": 30})
+	assert.Equal(t, map[string]int{"a": 1, "b": 20, "c": 30}, m.GetMap())
+}
+
+func TestSafeMap_MergeSafeMap(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	m.Set("a", 1)
+
+	other := NewSafeMap[string, int]()
+	other.Set("a", 10)
+	other.Set("d", 40)
+
+	m.MergeSafeMap(other)
+	assert.Equal(t, map[string]int{"a": 10, "d": 40}, m.GetMap())
+	assert.Equal(t, map[string]int{"a": 10, "d": 40}, other.GetMap())
+
+	m.MergeSafeMap(m)
+	assert.Equal(t, 2, m.Length())
+}
+
+func TestSafeMap_DeleteFunc(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	n := m.DeleteFunc(func(key, val int) bool {
+		return val%2 == 0
+	})
+	assert.Equal(t, 5, n)
+	assert.Equal(t, 5, m.Length())
+	for key := range m.Keys() {
+		assert.Equal(t, 1, key%2)
+	}
+
+	assert.Equal(t, 0, m.DeleteFunc(func(int, int) bool { return false }))
+}
+
+func TestSafeMap_TransformValues(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	m.TransformValues(func(key, val int) int {
+		return key + val*10
+	})
+
+	for i := range 10 {
+		assert.Equal(t, i*11, m.Get(i))
+	}
+	assert.Equal(t, 10, m.Length())
+}
+
+func TestSafeMap_ForEach(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	defer m.Close()
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	// fn may write the map, its writes are not visited
+	visited := 0
+	m.ForEach(func(key, val int) bool {
+		assert.Equal(t, key, val)
+		m.Set(key+10, val)
+		visited++
+		return true
+	})
+	assert.Equal(t, 10, visited)
+	assert.Equal(t, 20, m.Length())
+
+	visited = 0
+	m.ForEach(func(int, int) bool {
+		visited++
+		return visited < 5
+	})
+	assert.Equal(t, 5, visited)
+}
+
+func TestSafeMap_ForEachLocked(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	defer m.Close()
+	for i := range 10 {
+		m.Set(i, 100)
+	}
+
+	// another goroutine moves units between keys, the total never changes within a single operation
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			m.Txn(func(tx Tx[int, int]) error {
+				from, _ := tx.Get(i % 10)
+				to, _ := tx.Get((i + 1) % 10)
+				tx.Set(i%10, from-1)
+				tx.Set((i+1)%10, to+1)
+				return nil
+			})
+		}
+	}()
+	for range 100 {
+		total := 0
+		m.ForEachLocked(func(_, val int) bool {
+			total += val
+			return true
+		})
+		assert.Equal(t, 1000, total)
+	}
+	close(stop)
+	wg.Wait()
+
+	visited := 0
+	m.ForEachLocked(func(int, int) bool {
+		visited++
+		return false
+	})
+	assert.Equal(t, 1, visited)
+
+	// the writes wait for fn even when reads are served from a snapshot
+	for name, opt := range map[string]Option[int, int]{
+		"snapshot": WithSnapshotReads[int, int](),
+		"shared":   WithReadConcurrency[int, int](4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opt)
+			defer m.Close()
+			m.Set(1, 1)
+			written := make(chan struct{})
+			m.ForEachLocked(func(int, int) bool {
+				go func() {
+					m.Set(2, 2)
+					close(written)
+				}()
+				select {
+				case <-written:
+					t.Error("a write was applied while fn runs")
+				case <-time.After(20 * time.Millisecond):
+				}
+				return true
+			})
+			<-written
+			assert.Equal(t, 2, m.Get(2))
+		})
+	}
+}
+
+func TestReduce(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	sum := Reduce(m, 0, func(acc, key, val int) int {
+		return acc + val
+	})
+	assert.Equal(t, 45, sum)
+
+	maxKey := Reduce(m, -1, func(acc, key, val int) int {
+		return max(acc, key)
+	})
+	assert.Equal(t, 9, maxKey)
+
+	labels := Reduce(NewSafeMap[int, int](), "empty", func(acc string, key, val int) string {
+		return fmt.Sprint(acc, key)
+	})
+	assert.Equal(t, "empty", labels)
+}
+
+func TestSafeMap_Equal(t *testing.T) {
+	m1 := NewSafeMap[int, int]()
+	m2 := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m1.Set(i, i)
+		m2.Set(9-i, 9-i)
+	}
+
+	assert.True(t, m1.Equal(m2, nil))
+	assert.True(t, m1.Equal(m1, nil))
+
+	m2.Set(3, 13)
+	assert.False(t, m1.Equal(m2, nil))
+	assert.True(t, m1.Equal(m2, func(a, b int) bool { return a%10 == b%10 }))
+
+	m2.Delete(3)
+	assert.False(t, m1.Equal(m2, nil))
+
+	s1 := NewSafeMap[string, []int]()
+	s2 := NewSafeMap[string, []int]()
+	s1.Set("a", []int{1})
+	s2.Set("a", []int{1})
+	assert.True(t, s1.Equal(s2, slices.Equal))
+	assert.Panics(t, func() { s1.Equal(s2, nil) })
+}
+
+func TestSafeMap_String(t *testing.T) {
+	m := NewSafeMap[int, string]()
+	assert.Equal(t, "SafeMap[len=0]{}", m.String())
+
+	for i := 3; i > 0; i-- {
+		m.Set(i, fmt.Sprint("v", i))
+	}
+	assert.Equal(t, "SafeMap[len=3]{1:v1 2:v2 3:v3}", m.String())
+
+	for i := range 20 {
+		m.Set(i*10, "x")
+	}
+	assert.Equal(t, "SafeMap[len=23]{0:x 1:v1 2:v2 3:v3 10:x 20:x 30:x 40:x 50:x 60:x ...}", m.String())
+	assert.Equal(t, m.String(), fmt.Sprint(m))
+}
+
+func TestSafeMap_Close(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	for i := range 10 {
+		m.Set(i, i)
+	}
+
+	assert.NoError(t, m.Close())
+	assert.ErrorIs(t, m.Close(), ErrClosed)
+
+	m.Set(100, 100)
+	assert.Equal(t, 0, m.Get(1))
+	assert.False(t, m.Exist(100))
+	assert.Equal(t, 0, m.Length())
+	assert.Empty(t, m.GetMap())
+	val, ok := m.Lookup(1)
+	assert.False(t, ok)
+	assert.Equal(t, 0, val)
+	assert.Equal(t, 0, m.Clear())
+	assert.Equal(t, 0, m.Clone().Length())
+	for range m.All() {
+		assert.Fail(t, "closed map must be empty")
+	}
+
+	r := NewSafeMap(WithReadConcurrency[int, int](4))
+	r.Set(1, 1)
+	assert.NoError(t, r.Close())
+	assert.False(t, r.Exist(1))
+}
+
+func TestSafeMap_CloseConcurrent(t *testing.T) {
+	m := NewSafeMap[int, int]()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				m.Set(i, i)
+				m.Get(i)
+			}
+		}()
+	}
+
+	m.Close()
+	wg.Wait()
+	assert.Equal(t, 0, m.Length())
+}
+
+func TestSafeMap_Cleanup(t *testing.T) {
+	c := func() *core[int, int] {
+		m := NewSafeMap[int, int]()
+		m.Set(1, 1)
+		return m.core
+	}()
+
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		select {
+		case <-c.done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSafeMap_TryGet(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	m.Set("a", 1)
+
+	assert.Eventually(t, func() bool {
+		val, found, ok := m.TryGet("a")
+		return ok && found && val == 1
+	}, time.Second, time.Millisecond)
+
+	busy, release := make(chan struct{}), make(chan struct{})
+	go m.Update("busy", func(old int, exists bool) (int, bool) {
+		close(busy)
+		<-release
+		return old, exists
+	})
+	<-busy
+	_, _, ok := m.TryGet("a")
+	assert.False(t, ok)
+	close(release)
+
+	m.Close()
+	_, found, ok := m.TryGet("a")
+	assert.False(t, found)
+	assert.False(t, ok)
+}
+
+func TestSafeMap_TrySet(t *testing.T) {
+	m := NewSafeMap[string, int]()
 
-	m := &SafeMap[int, int]{}
-	assert.Panics(t, func() { m.Get(1) })
-	assert.Panics(t, func() { m.Set(1, 1) })
-	assert.Panics(t, func() { m.Delete(1) })
-	assert.Panics(t, func() { m.Exist(1) })
-	assert.Panics(t, func() { m.Keys() })
-	assert.Panics(t, func() { m.All() })
-	assert.Panics(t, func() { m.Length() })
-	assert.Panics(t, func() { m.GetMap() })
+	assert.Eventually(t, func() bool {
+		return m.TrySet("a", 1)
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, m.Get("a"))
 
+	busy, release := make(chan struct{}), make(chan struct{})
+	go m.Update("busy", func(old int, exists bool) (int, bool) {
+		close(busy)
+		<-release
+		return old, exists
+	})
+	<-busy
+	assert.False(t, m.TrySet("b", 2))
+	close(release)
+	assert.False(t, m.Exist("b"))
+
+	r := NewSafeMap(WithReadConcurrency[string, int](2))
+	r.Set("a", 1)
+	val, found, ok := r.TryGet("a")
+	assert.True(t, ok)
+	assert.True(t, found)
+	assert.Equal(t, 1, val)
+}
+
+func TestSafeMap_ZeroValue(t *testing.T) {
+	var m SafeMap[int, int]
+
+	assert.Equal(t, 0, m.Get(1))
+	m.Set(1, 1)
+	assert.Equal(t, 1, m.Get(1))
+	assert.True(t, m.Exist(1))
+	assert.Equal(t, 1, m.Length())
+	assert.Equal(t, map[int]int{1: 1}, m.GetMap())
+
+	type server struct {
+		sessions SafeMap[string, int]
+	}
+	srv := &server{}
+	srv.sessions.Set("a", 1)
+	assert.Equal(t, 1, srv.sessions.Get("a"))
+
+	var concurrent SafeMap[int, int]
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			concurrent.Set(i, i)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, concurrent.Length())
+
+	other := NewSafeMap[int, int]()
+	var empty SafeMap[int, int]
+	other.MergeSafeMap(&empty)
+	assert.True(t, other.Equal(&empty, nil))
+
+	var closed SafeMap[int, int]
+	assert.NoError(t, closed.Close())
+	closed.Set(1, 1)
+	assert.False(t, closed.Exist(1))
+}
+
+func TestSafeMap_ReplyAllocs(t *testing.T) {
+	m := NewSafeMap(WithReadConcurrency[int, int](2))
+	m.Set(1, 1000)
+
+	// replies are typed, reads served outside the worker don't allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Get(1)
+		m.Exist(1)
+		m.Length()
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkSafeMap_Get(b *testing.B) {
+	m := NewSafeMap[int, int]()
+	for i := range 1024 {
+		m.Set(i, i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		m.Get(i & 1023)
+	}
+}
+
+func TestSafeMap_Batch(t *testing.T) {
+	m := NewSafeMap(WithQueueSize[int, int](2 * maxBatchSize))
+
+	// keep the worker busy so the next operations pile up in the queue and are applied as batches
+	started, release := make(chan struct{}), make(chan struct{})
+	go m.Update(-1, func(old int, exists bool) (int, bool) {
+		close(started)
+		<-release
+		return old, exists
+	})
+	<-started
+
+	var wg sync.WaitGroup
+	for i := range 2 * maxBatchSize {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i == maxBatchSize/2 {
+				assert.PanicsWithValue(t, "boom", func() {
+					m.Update(i, func(int, bool) (int, bool) { panic("boom") })
+				})
+				return
+			}
+			assert.Equal(t, i, m.Upsert(i, i, nil))
+		}()
+	}
+	assert.Eventually(t, func() bool { return len(m.opChan) == 2*maxBatchSize }, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	// a panic in the middle of a batch does not affect the other operations
+	assert.Equal(t, 2*maxBatchSize-1, m.Length())
+	assert.False(t, m.Exist(maxBatchSize/2))
+}
+
+func BenchmarkSafeMap_Set(b *testing.B) {
+	m := NewSafeMap(WithQueueSize[int, int](64))
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Set(i&1023, i)
+			i++
+		}
+	})
+}
+
+func TestNewSafeMapWithCapacity(t *testing.T) {
+	m := NewSafeMapWithCapacity[int, int](1000, WithReadConcurrency[int, int](2))
+	assert.Equal(t, 1000, m.cfg.capacity)
+	assert.Equal(t, 2, m.cfg.readConcurrency)
+
+	for i := range 1000 {
+		m.Set(i, i)
+	}
+	assert.Equal(t, 1000, m.Length())
+
+	assert.Equal(t, 0, NewSafeMapWithCapacity[int, int](-1).Length())
+}
+
+func TestNewSafeMapFrom(t *testing.T) {
+	src := map[string]int{"a": 1, "b": 2, "c": 3}
+	m := NewSafeMapFrom(src, WithMutex[string, int]())
+
+	assert.Equal(t, src, m.GetMap())
+
+	// the source map is copied, not retained
+	src["d"] = 4
+	m.Set("a", 10)
+	assert.False(t, m.Exist("d"))
+	assert.Equal(t, 1, src["a"])
+
+	assert.Equal(t, 0, NewSafeMapFrom[string, int](nil).Length())
+}
+
+func BenchmarkNewSafeMapWithCapacity(b *testing.B) {
+	for _, capacity := range []int{0, 100_000} {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				m := NewSafeMapWithCapacity[int, int](capacity)
+				for i := range 100_000 {
+					m.Set(i, i)
+				}
+				m.Close()
+			}
+		})
+	}
 }
 
 func TestSafeMapRace(t *testing.T) {
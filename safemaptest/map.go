@@ -0,0 +1,235 @@
+// Package safemaptest provides an implementation of safemap.Map for unit tests.
+package safemaptest
+
+import (
+	"iter"
+	"maps"
+	"slices"
+
+	"github.com/elangreza/safemap"
+)
+
+// Map is a non-concurrent implementation of safemap.Map for unit tests. It runs every operation in the
+// calling goroutine and iterates the entries in the order their keys were inserted, so tests are deterministic.
+// It must not be used from several goroutines, and the functions given to Update and Upsert may call its methods.
+// Like SafeMap, after Close reads behave as on an empty map and writes are ignored.
+type Map[k comparable, v any] struct {
+	items  map[k]v
+	order  []k
+	closed bool
+}
+
+var _ safemap.Map[string, int] = (*Map[string, int])(nil)
+
+// NewMap creates an empty Map.
+// example
+//
+//	sessions := safemaptest.NewMap[string, Session]()
+//	svc := &Service{sessions: sessions}
+func NewMap[k comparable, v any]() *Map[k, v] {
+	return &Map[k, v]{items: make(map[k]v)}
+}
+
+// NewMapFrom creates a Map holding the given entries, inserted in the order of the sorted keys
+// when cmp is not nil, or in an unspecified order otherwise.
+func NewMapFrom[k comparable, v any](items map[k]v, cmp func(a, b k) int) *Map[k, v] {
+	m := NewMap[k, v]()
+	keys := slices.Collect(maps.Keys(items))
+	if cmp != nil {
+		slices.SortFunc(keys, cmp)
+	}
+	for _, key := range keys {
+		m.Set(key, items[key])
+	}
+	return m
+}
+
+// store sets the value for the key, appending the key to the insertion order if it is new.
+func (m *Map[k, v]) store(key k, val v) {
+	if m.closed {
+		return
+	}
+	if _, ok := m.items[key]; !ok {
+		m.order = append(m.order, key)
+	}
+	m.items[key] = val
+}
+
+// remove deletes the key and returns the value it held.
+func (m *Map[k, v]) remove(key k) (val v, ok bool) {
+	val, ok = m.items[key]
+	if !ok {
+		return val, false
+	}
+	delete(m.items, key)
+	m.order = slices.DeleteFunc(m.order, func(other k) bool { return other == key })
+	return val, true
+}
+
+// Set sets the value for the given key.
+func (m *Map[k, v]) Set(key k, val v) {
+	m.store(key, val)
+}
+
+// Get returns the value for the given key, or the zero value if the key is not present.
+func (m *Map[k, v]) Get(key k) v {
+	return m.items[key]
+}
+
+// Lookup returns the value for the given key and whether it is present.
+func (m *Map[k, v]) Lookup(key k) (val v, ok bool) {
+	val, ok = m.items[key]
+	return val, ok
+}
+
+// Delete removes the given key.
+func (m *Map[k, v]) Delete(key k) {
+	m.remove(key)
+}
+
+// Exist reports whether the given key is present.
+func (m *Map[k, v]) Exist(key k) bool {
+	_, ok := m.items[key]
+	return ok
+}
+
+// GetOrSet returns the existing value for the given key if present, otherwise it stores the given value and returns it.
+func (m *Map[k, v]) GetOrSet(key k, val v) (actual v, loaded bool) {
+	if old, ok := m.items[key]; ok {
+		return old, true
+	}
+	m.store(key, val)
+	return val, false
+}
+
+// GetAndDelete removes the given key and returns the value it held, if any.
+func (m *Map[k, v]) GetAndDelete(key k) (val v, ok bool) {
+	return m.remove(key)
+}
+
+// Swap stores the value for the given key and returns the previous value, if any.
+func (m *Map[k, v]) Swap(key k, val v) (previous v, loaded bool) {
+	previous, loaded = m.items[key]
+	m.store(key, val)
+	return previous, loaded
+}
+
+// CompareAndSwap stores new for the given key if its value is equal to old.
+// The value type must be comparable, otherwise the comparison panics.
+func (m *Map[k, v]) CompareAndSwap(key k, old, new v) (swapped bool) {
+	if cur, ok := m.items[key]; !ok || any(cur) != any(old) {
+		return false
+	}
+	m.store(key, new)
+	return true
+}
+
+// CompareAndDelete deletes the given key if its value is equal to old.
+// The value type must be comparable, otherwise the comparison panics.
+func (m *Map[k, v]) CompareAndDelete(key k, old v) (deleted bool) {
+	if cur, ok := m.items[key]; !ok || any(cur) != any(old) {
+		return false
+	}
+	m.remove(key)
+	return true
+}
+
+// Update computes a new value for the given key, deleting the key when keep is false.
+// It returns the resulting value and whether the key is present afterwards.
+func (m *Map[k, v]) Update(key k, fn func(old v, exists bool) (new v, keep bool)) (val v, ok bool) {
+	if m.closed {
+		return val, false
+	}
+	old, exists := m.items[key]
+	val, keep := fn(old, exists)
+	if !keep {
+		m.remove(key)
+		var zero v
+		return zero, false
+	}
+	m.store(key, val)
+	return val, true
+}
+
+// Upsert inserts the value for the given key if it is absent, otherwise it stores the result of merge.
+// It returns the stored value.
+func (m *Map[k, v]) Upsert(key k, val v, merge func(existing, incoming v) v) v {
+	if old, ok := m.items[key]; ok {
+		val = merge(old, val)
+	}
+	m.store(key, val)
+	return val
+}
+
+// SetIfAbsent sets the value for the given key if it is not present, and reports whether it was set.
+func (m *Map[k, v]) SetIfAbsent(key k, val v) bool {
+	if m.closed || m.Exist(key) {
+		return false
+	}
+	m.store(key, val)
+	return true
+}
+
+// SetIfPresent sets the value for the given key if it is present, and reports whether it was set.
+func (m *Map[k, v]) SetIfPresent(key k, val v) bool {
+	if m.closed || !m.Exist(key) {
+		return false
+	}
+	m.store(key, val)
+	return true
+}
+
+// Length returns the number of entries.
+func (m *Map[k, v]) Length() int {
+	return len(m.items)
+}
+
+// GetMap returns a copy of the entries.
+func (m *Map[k, v]) GetMap() map[k]v {
+	return maps.Clone(m.items)
+}
+
+// All returns an iterator over the entries, in insertion order, of the Map at the time All is called.
+func (m *Map[k, v]) All() iter.Seq2[k, v] {
+	order, items := slices.Clone(m.order), maps.Clone(m.items)
+	return func(yield func(k, v) bool) {
+		for _, key := range order {
+			if !yield(key, items[key]) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys, in insertion order, of the Map at the time Keys is called.
+func (m *Map[k, v]) Keys() iter.Seq[k] {
+	return slices.Values(slices.Clone(m.order))
+}
+
+// Values returns an iterator over the values, in insertion order, of the Map at the time Values is called.
+func (m *Map[k, v]) Values() iter.Seq[v] {
+	values := make([]v, 0, len(m.order))
+	for _, key := range m.order {
+		values = append(values, m.items[key])
+	}
+	return slices.Values(values)
+}
+
+// Clear removes all entries and returns the number of entries removed.
+func (m *Map[k, v]) Clear() int {
+	n := len(m.items)
+	clear(m.items)
+	m.order = nil
+	return n
+}
+
+// Close releases the entries, reads then behave as on an empty Map and writes are ignored.
+// Close returns safemap.ErrClosed if the Map was already closed.
+func (m *Map[k, v]) Close() error {
+	if m.closed {
+		return safemap.ErrClosed
+	}
+	m.closed = true
+	m.Clear()
+	return nil
+}
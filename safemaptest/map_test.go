@@ -0,0 +1,70 @@
+package safemaptest
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/elangreza/safemap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	var m safemap.Map[string, int] = NewMap[string, int]()
+
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("c", 3)
+	m.Set("b", 4)
+	assert.Equal(t, []string{"b", "a", "c"}, slices.Collect(m.Keys()), "insertion order")
+	assert.Equal(t, []int{4, 2, 3}, slices.Collect(m.Values()))
+	assert.Equal(t, 4, m.Get("b"))
+
+	actual, loaded := m.GetOrSet("a", 5)
+	assert.True(t, loaded)
+	assert.Equal(t, 2, actual)
+	previous, loaded := m.Swap("d", 5)
+	assert.False(t, loaded)
+	assert.Zero(t, previous)
+
+	assert.True(t, m.CompareAndSwap("d", 5, 6))
+	assert.False(t, m.CompareAndSwap("d", 5, 7))
+	assert.False(t, m.CompareAndDelete("d", 5))
+	assert.True(t, m.CompareAndDelete("d", 6))
+
+	val, ok := m.GetAndDelete("b")
+	assert.True(t, ok)
+	assert.Equal(t, 4, val)
+	m.Set("b", 1)
+	assert.Equal(t, []string{"a", "c", "b"}, slices.Collect(m.Keys()), "a deleted key is inserted again at the end")
+
+	// the functions may call the map, since it runs them in the caller
+	val, ok = m.Update("a", func(old int, exists bool) (int, bool) {
+		return old + m.Get("c"), exists
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 5, val)
+	_, ok = m.Update("a", func(int, bool) (int, bool) { return 0, false })
+	assert.False(t, ok)
+	assert.Equal(t, 3, m.Upsert("c", 10, func(existing, _ int) int { return existing }))
+	assert.False(t, m.SetIfAbsent("c", 1))
+	assert.False(t, m.SetIfPresent("a", 1))
+
+	assert.Equal(t, map[string]int{"c": 3, "b": 1}, m.GetMap())
+	for key := range m.All() {
+		m.Delete(key)
+	}
+	assert.Zero(t, m.Length())
+
+	m.Set("a", 1)
+	assert.NoError(t, m.Close())
+	assert.ErrorIs(t, m.Close(), safemap.ErrClosed)
+	m.Set("b", 2)
+	assert.False(t, m.Exist("a"))
+	assert.False(t, m.Exist("b"))
+}
+
+func TestNewMapFrom(t *testing.T) {
+	m := NewMapFrom(map[string]int{"c": 3, "a": 1, "b": 2}, cmp.Compare[string])
+	assert.Equal(t, []string{"a", "b", "c"}, slices.Collect(m.Keys()))
+}
@@ -0,0 +1,128 @@
+package safemap
+
+import (
+	"iter"
+	"maps"
+)
+
+// SafeSet is a thread-safe set backed by a SafeMap, so its operations are serialized by the same worker goroutine.
+// The zero value is an empty set ready to use. A SafeSet must not be copied after first use.
+// example
+//
+//	var seen SafeSet[string]
+//	if seen.Add(id) {
+//		process(id)
+//	}
+type SafeSet[T comparable] struct {
+	m SafeMap[T, struct{}]
+}
+
+// NewSafeSet creates a SafeSet holding the given items.
+// example
+//
+//	s := NewSafeSet("a", "b")
+func NewSafeSet[T comparable](items ...T) *SafeSet[T] {
+	s := &SafeSet[T]{}
+	s.m.configure([]Option[T, struct{}]{WithCapacity[T, struct{}](len(items))})
+	s.AddMany(items...)
+	return s
+}
+
+// newSafeSetFrom creates a SafeSet holding the keys of items, which is retained.
+func newSafeSetFrom[T comparable](items map[T]struct{}) *SafeSet[T] {
+	s := &SafeSet[T]{}
+	s.m.init(config[T, struct{}]{}, mapBackend[T, struct{}](items))
+	return s
+}
+
+// Add adds the item to the SafeSet and reports whether it was added, false if it was already present.
+func (s *SafeSet[T]) Add(item T) bool {
+	return s.m.SetIfAbsent(item, struct{}{})
+}
+
+// AddMany adds all the items to the SafeSet in a single operation.
+func (s *SafeSet[T]) AddMany(items ...T) {
+	if len(items) == 0 {
+		return
+	}
+	set := make(map[T]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	s.m.SetMany(set)
+}
+
+// Remove removes the item from the SafeSet and reports whether it was present.
+func (s *SafeSet[T]) Remove(item T) bool {
+	_, ok := s.m.GetAndDelete(item)
+	return ok
+}
+
+// Contains reports whether the item is in the SafeSet.
+func (s *SafeSet[T]) Contains(item T) bool {
+	return s.m.Exist(item)
+}
+
+// Len returns the number of items in the SafeSet.
+func (s *SafeSet[T]) Len() int {
+	return s.m.Length()
+}
+
+// All returns an iterator over the items of the SafeSet at the time All is called, in an unspecified order.
+// example
+//
+//	for item := range s.All() {
+//		fmt.Println(item)
+//	}
+func (s *SafeSet[T]) All() iter.Seq[T] {
+	return s.m.Snapshot().Keys()
+}
+
+// Items returns a copy of the items of the SafeSet.
+func (s *SafeSet[T]) Items() map[T]struct{} {
+	return s.m.GetMap()
+}
+
+// Union returns a new SafeSet holding the items present in s, other or both.
+// Each set is read in a single operation, but the two are not read at the same instant.
+func (s *SafeSet[T]) Union(other *SafeSet[T]) *SafeSet[T] {
+	items := s.m.GetMap()
+	maps.Copy(items, other.m.Snapshot().items)
+	return newSafeSetFrom(items)
+}
+
+// Intersect returns a new SafeSet holding the items present in both s and other.
+// Each set is read in a single operation, but the two are not read at the same instant.
+func (s *SafeSet[T]) Intersect(other *SafeSet[T]) *SafeSet[T] {
+	a, b := s.m.Snapshot().items, other.m.Snapshot().items
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	items := make(map[T]struct{})
+	for item := range a {
+		if _, ok := b[item]; ok {
+			items[item] = struct{}{}
+		}
+	}
+	return newSafeSetFrom(items)
+}
+
+// Difference returns a new SafeSet holding the items present in s but not in other.
+// Each set is read in a single operation, but the two are not read at the same instant.
+func (s *SafeSet[T]) Difference(other *SafeSet[T]) *SafeSet[T] {
+	items := s.m.GetMap()
+	for item := range other.m.Snapshot().items {
+		delete(items, item)
+	}
+	return newSafeSetFrom(items)
+}
+
+// Clear removes all the items of the SafeSet and returns the number of items removed.
+func (s *SafeSet[T]) Clear() int {
+	return s.m.Clear()
+}
+
+// Close stops the worker goroutine of the SafeSet, see SafeMap.Close.
+func (s *SafeSet[T]) Close() error {
+	return s.m.Close()
+}
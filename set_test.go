@@ -0,0 +1,67 @@
+package safemap
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeSet(t *testing.T) {
+	var s SafeSet[string]
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	var added sync.Map
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if s.Add(fmt.Sprint(i)) {
+					added.Store(i, w)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	added.Range(func(any, any) bool { n++; return true })
+	assert.Equal(t, 100, n, "each item is added once")
+	assert.Equal(t, 100, s.Len())
+	assert.True(t, s.Contains("42"))
+	assert.True(t, s.Remove("42"))
+	assert.False(t, s.Remove("42"))
+	assert.False(t, s.Contains("42"))
+	assert.Len(t, slices.Collect(s.All()), 99)
+	assert.Equal(t, 99, s.Clear())
+}
+
+func TestSafeSet_Operations(t *testing.T) {
+	a := NewSafeSet(1, 2, 3)
+	defer a.Close()
+	b := NewSafeSet(2, 3, 4)
+	defer b.Close()
+
+	union := a.Union(b)
+	defer union.Close()
+	assert.Equal(t, map[int]struct{}{1: {}, 2: {}, 3: {}, 4: {}}, union.Items())
+
+	intersection := a.Intersect(b)
+	defer intersection.Close()
+	assert.Equal(t, map[int]struct{}{2: {}, 3: {}}, intersection.Items())
+
+	difference := a.Difference(b)
+	defer difference.Close()
+	assert.Equal(t, map[int]struct{}{1: {}}, difference.Items())
+
+	// the results are independent of their operands
+	union.Add(5)
+	intersection.Remove(2)
+	assert.Equal(t, 3, a.Len())
+	assert.Equal(t, 3, b.Len())
+	assert.True(t, b.Contains(2))
+}
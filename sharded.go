@@ -0,0 +1,317 @@
+package safemap
+
+import (
+	"hash/maphash"
+	"io"
+	"iter"
+	"maps"
+	"runtime"
+	"sync"
+)
+
+// ShardedSafeMap is a thread-safe map spreading its keys across several independent SafeMaps,
+// each with its own worker goroutine, so operations on keys living in different shards don't serialize
+// through a single goroutine. Operations on a single key are atomic as with SafeMap,
+// but operations spanning every shard such as Length, GetMap or the iterators visit the shards one by one
+// and are not a consistent snapshot of the whole map while it is being modified.
+// The zero value is an empty map ready to use, with one shard per available CPU created on first use.
+// NewShardedSafeMap is only needed to choose the number of shards or pass options.
+// A ShardedSafeMap must not be copied after first use.
+type ShardedSafeMap[k comparable, v any] struct {
+	shards []*SafeMap[k, v]
+	seed   maphash.Seed
+
+	// once lazily creates the shards of a zero value ShardedSafeMap.
+	once sync.Once
+}
+
+// NewShardedSafeMap creates a ShardedSafeMap with the given number of shards.
+// A number of shards lower than 1 uses one shard per available CPU.
+// The options are checked as by NewSafeMap and applied to every shard, except the bounds of the whole map given
+// by WithCapacity, WithMaxEntries and WithMaxBytes, which are divided across the shards. Each shard evicts
+// once it exceeds its share, at least one entry, so the map may evict before reaching the bound when the keys
+// are unevenly spread. Other options such as WithReadConcurrency or WithQueueSize apply to each shard.
+// WithBackend and WithInsertionOrder can't be used since a backend or an order can't be shared between shards,
+// nor WithAutoSnapshot since every shard would overwrite the file with its own entries, see Save instead.
+// example
+//
+//	m := NewShardedSafeMap[string, int](16)
+//	m.Set("a", 1)
+func NewShardedSafeMap[k comparable, v any](shards int, opts ...Option[k, v]) *ShardedSafeMap[k, v] {
+	sm := &ShardedSafeMap[k, v]{}
+	sm.configure(shards, opts)
+	return sm
+}
+
+// configure applies the options and creates the shards of the ShardedSafeMap, see NewShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) configure(shards int, opts []Option[k, v]) {
+	cfg := newConfig(opts)
+	if cfg.backend != nil {
+		panic("safemap: WithBackend can't be used with a ShardedSafeMap")
+	}
+	if cfg.insertionOrder {
+		panic("safemap: WithInsertionOrder can't be used with a ShardedSafeMap")
+	}
+	if cfg.snapshotPath != "" {
+		panic("safemap: WithAutoSnapshot can't be used with a ShardedSafeMap")
+	}
+
+	if cfg.wal != nil {
+		cfg.wal = &lockedWriter{w: cfg.wal}
+	}
+
+	if shards < 1 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+
+	s.shards = make([]*SafeMap[k, v], shards)
+	s.seed = maphash.MakeSeed()
+	for i := range s.shards {
+		shard := cfg
+		shard.capacity = share(cfg.capacity, shards, i)
+		shard.maxEntries = share(cfg.maxEntries, shards, i)
+		shard.maxBytes = share(cfg.maxBytes, shards, i)
+		s.shards[i] = newSafeMap(shard, shard.newBackend())
+	}
+}
+
+// load returns the shards of the ShardedSafeMap, creating one per available CPU with the default configuration
+// on first use of a zero value ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) load() []*SafeMap[k, v] {
+	s.once.Do(func() {
+		if s.shards == nil {
+			s.configure(0, nil)
+		}
+	})
+	return s.shards
+}
+
+// share returns the part of the bound n held by the i-th of the given number of shards, the remainder going
+// to the first shards so the parts add up to n. A positive bound gives at least 1 to every shard,
+// since a bound lower than or equal to zero stands for no bound.
+func share(n, shards, i int) int {
+	if n <= 0 {
+		return n
+	}
+	part := n / shards
+	if i < n%shards {
+		part++
+	}
+	return max(part, 1)
+}
+
+// shard returns the SafeMap owning the given key.
+func (s *ShardedSafeMap[k, v]) shard(key k) *SafeMap[k, v] {
+	shards := s.load()
+	return shards[maphash.Comparable(s.seed, key)%uint64(len(shards))]
+}
+
+// Shards returns the number of shards of the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Shards() int {
+	return len(s.load())
+}
+
+// Set sets the value for the given key in the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Set(key k, val v) {
+	s.shard(key).Set(key, val)
+}
+
+// Get retrieves the value for the given key from the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Get(key k) v {
+	return s.shard(key).Get(key)
+}
+
+// Lookup retrieves the value for the given key from the ShardedSafeMap and reports whether the key was present.
+func (s *ShardedSafeMap[k, v]) Lookup(key k) (val v, ok bool) {
+	return s.shard(key).Lookup(key)
+}
+
+// Delete removes the key-value pair for the given key from the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Delete(key k) {
+	s.shard(key).Delete(key)
+}
+
+// Exist checks if the given key exists in the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Exist(key k) bool {
+	return s.shard(key).Exist(key)
+}
+
+// GetOrSet returns the existing value for the given key if present, otherwise it stores the given value and returns it.
+// See SafeMap.GetOrSet.
+func (s *ShardedSafeMap[k, v]) GetOrSet(key k, val v) (actual v, loaded bool) {
+	return s.shard(key).GetOrSet(key, val)
+}
+
+// GetAndDelete removes the given key and returns its value, see SafeMap.GetAndDelete.
+func (s *ShardedSafeMap[k, v]) GetAndDelete(key k) (val v, ok bool) {
+	return s.shard(key).GetAndDelete(key)
+}
+
+// Swap stores the value for the given key and returns the previous value, see SafeMap.Swap.
+func (s *ShardedSafeMap[k, v]) Swap(key k, val v) (previous v, loaded bool) {
+	return s.shard(key).Swap(key, val)
+}
+
+// CompareAndSwap swaps the value of the given key if it equals old, see SafeMap.CompareAndSwap.
+func (s *ShardedSafeMap[k, v]) CompareAndSwap(key k, old, new v) (swapped bool) {
+	return s.shard(key).CompareAndSwap(key, old, new)
+}
+
+// CompareAndDelete deletes the given key if its value equals old, see SafeMap.CompareAndDelete.
+func (s *ShardedSafeMap[k, v]) CompareAndDelete(key k, old v) (deleted bool) {
+	return s.shard(key).CompareAndDelete(key, old)
+}
+
+// Update atomically computes a new value for the given key, see SafeMap.Update.
+// The fn function runs inside the worker goroutine of the key's shard and must not call methods of the same ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Update(key k, fn func(old v, exists bool) (new v, keep bool)) (val v, ok bool) {
+	return s.shard(key).Update(key, fn)
+}
+
+// Upsert inserts the value for the given key or merges it with the existing one, see SafeMap.Upsert.
+// The merge function runs inside the worker goroutine of the key's shard and must not call methods of the same ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Upsert(key k, val v, merge func(existing, incoming v) v) v {
+	return s.shard(key).Upsert(key, val, merge)
+}
+
+// SetIfAbsent sets the value for the given key only if the key is not present and reports whether the value was set.
+func (s *ShardedSafeMap[k, v]) SetIfAbsent(key k, val v) bool {
+	return s.shard(key).SetIfAbsent(key, val)
+}
+
+// SetIfPresent sets the value for the given key only if the key is already present and reports whether the value was set.
+func (s *ShardedSafeMap[k, v]) SetIfPresent(key k, val v) bool {
+	return s.shard(key).SetIfPresent(key, val)
+}
+
+// Length returns the number of key-value pairs in the ShardedSafeMap.
+func (s *ShardedSafeMap[k, v]) Length() int {
+	n := 0
+	for _, shard := range s.load() {
+		n += shard.Length()
+	}
+	return n
+}
+
+// Stats returns the sum of the counters of every shard, see SafeMap.Stats.
+func (s *ShardedSafeMap[k, v]) Stats() Stats {
+	var stats Stats
+	for _, shard := range s.load() {
+		stats = stats.add(shard.Stats())
+	}
+	return stats
+}
+
+// Save writes the entries of every shard to the file at path as a single file, see SafeMap.Save,
+// restored with NewSafeMapFromFile. The shards are read one by one as by GetMap.
+func (s *ShardedSafeMap[k, v]) Save(path string) error {
+	data, err := s.load()[0].load().marshalBinary(s.GetMap())
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// ReplayWAL restores the entries from a write-ahead log written with WithWAL, see SafeMap.ReplayWAL.
+// The records are dispatched to the shards owning their keys, each shard applying its records in a single operation.
+func (s *ShardedSafeMap[k, v]) ReplayWAL(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	keys, values := s.load()[0].load().codecs()
+	records, err := readWAL(data, keys, values)
+	byShard := make(map[*SafeMap[k, v]][]walRecord[k, v])
+	for _, rec := range records {
+		shard := s.shard(rec.key)
+		byShard[shard] = append(byShard[shard], rec)
+	}
+	for shard, records := range byShard {
+		shard.send(operation[k, v]{op: "replay", replay: records})
+	}
+	return err
+}
+
+// WALError returns the first error that stopped the write-ahead log of a shard, see SafeMap.WALError.
+func (s *ShardedSafeMap[k, v]) WALError() error {
+	for _, shard := range s.load() {
+		if err := shard.WALError(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMap returns a copy of the entries of every shard merged into a single map.
+func (s *ShardedSafeMap[k, v]) GetMap() map[k]v {
+	items := make(map[k]v)
+	for _, shard := range s.load() {
+		maps.Copy(items, shard.GetMap())
+	}
+	return items
+}
+
+// All returns an iterator over all key-value pairs in the ShardedSafeMap.
+// Each shard is snapshotted when the iteration reaches it.
+// example
+//
+//	m := NewShardedSafeMap[int, int](4)
+//	m.Set(1, 2)
+//	for key, value := range m.All() {
+//		fmt.Println(key, value)
+//	}
+func (s *ShardedSafeMap[k, v]) All() iter.Seq2[k, v] {
+	return func(yield func(k, v) bool) {
+		for _, shard := range s.load() {
+			for key, val := range shard.All() {
+				if !yield(key, val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over all keys in the ShardedSafeMap, see All.
+func (s *ShardedSafeMap[k, v]) Keys() iter.Seq[k] {
+	return func(yield func(k) bool) {
+		for key := range s.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over all values in the ShardedSafeMap, see All.
+func (s *ShardedSafeMap[k, v]) Values() iter.Seq[v] {
+	return func(yield func(v) bool) {
+		for _, val := range s.All() {
+			if !yield(val) {
+				return
+			}
+		}
+	}
+}
+
+// Clear removes all entries from the ShardedSafeMap and returns the number of entries removed.
+func (s *ShardedSafeMap[k, v]) Clear() int {
+	n := 0
+	for _, shard := range s.load() {
+		n += shard.Clear()
+	}
+	return n
+}
+
+// Close stops the worker goroutines of every shard and releases their entries.
+// Close returns ErrClosed if the ShardedSafeMap was already closed.
+func (s *ShardedSafeMap[k, v]) Close() error {
+	var err error
+	for _, shard := range s.load() {
+		if shard.Close() != nil {
+			err = ErrClosed
+		}
+	}
+	return err
+}
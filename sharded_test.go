@@ -0,0 +1,105 @@
+package safemap
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedSafeMap(t *testing.T) {
+	m := NewShardedSafeMap[int, int](4)
+	assert.Equal(t, 4, m.Shards())
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w * 100; i < (w+1)*100; i++ {
+				m.Set(i, i)
+				m.Update(i, func(old int, exists bool) (int, bool) {
+					return old * 2, exists
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 800, m.Length())
+	assert.Len(t, m.GetMap(), 800)
+	for key, val := range m.All() {
+		assert.Equal(t, key*2, val)
+	}
+
+	val, ok := m.Lookup(10)
+	assert.True(t, ok)
+	assert.Equal(t, 20, val)
+	assert.False(t, m.SetIfAbsent(10, 1))
+	assert.True(t, m.CompareAndSwap(10, 20, 1))
+	assert.Equal(t, 1, m.Get(10))
+
+	m.Delete(10)
+	assert.False(t, m.Exist(10))
+	assert.Equal(t, 799, m.Clear())
+	assert.Equal(t, 0, m.Length())
+
+	assert.NoError(t, m.Close())
+	assert.ErrorIs(t, m.Close(), ErrClosed)
+
+	assert.Positive(t, NewShardedSafeMap[int, int](0).Shards())
+	assert.Panics(t, func() {
+		NewShardedSafeMap(2, WithBackend[int, int](make(mapBackend[int, int])))
+	})
+}
+
+func TestNewShardedSafeMap_Options(t *testing.T) {
+	// the options are checked as for a SafeMap
+	assert.PanicsWithValue(t, "safemap: WithCopyOnWrite can't be used with WithBackend", func() {
+		NewShardedSafeMap(2, WithCopyOnWrite[int, int](), WithBackend[int, int](make(mapBackend[int, int])))
+	})
+
+	// the bounds hold for the whole map
+	m := NewShardedSafeMap(8, WithMaxEntries[int, int](100))
+	defer m.Close()
+	for i := range 1000 {
+		m.Set(i, i)
+	}
+	assert.LessOrEqual(t, m.Length(), 100)
+	assert.Positive(t, m.Length())
+
+	total := 0
+	for i := range 4 {
+		total += share(10, 4, i)
+		assert.Equal(t, 1, share(2, 4, i))
+		assert.Zero(t, share(0, 4, i))
+	}
+	assert.Equal(t, 10, total)
+}
+
+func TestShardedSafeMap_ZeroValue(t *testing.T) {
+	var m ShardedSafeMap[string, int]
+	m.Set("a", 1)
+	assert.Equal(t, 1, m.Get("a"))
+	assert.Equal(t, runtime.GOMAXPROCS(0), m.Shards())
+	assert.Equal(t, 1, m.Length())
+	assert.NoError(t, m.Close())
+}
+
+func BenchmarkShardedSafeMap(b *testing.B) {
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			m := NewShardedSafeMap[int, int](shards)
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					m.Set(i&1023, i)
+					i++
+				}
+			})
+		})
+	}
+}
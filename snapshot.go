@@ -0,0 +1,89 @@
+package safemap
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// Snapshot is a read-only view of the entries of a SafeMap at the time it was taken, returned by SafeMap.Snapshot.
+// It never changes afterwards, and can be read from several goroutines without synchronization.
+type Snapshot[k comparable, v any] struct {
+	items map[k]v
+
+	// order holds the keys in insertion order for a SafeMap created WithInsertionOrder, it is nil otherwise.
+	order []k
+}
+
+// Snapshot returns a read-only view of the entries of the SafeMap, unaffected by the writes that follow.
+// Taking a snapshot doesn't copy the entries: the SafeMap copies them on its next write instead,
+// so repeated snapshots between writes are free. A SafeMap with a custom Backend copies the entries right away.
+// Values are shared as is, so reference types such as slices or pointers must not be modified.
+// example
+//
+//	snap := m.Snapshot()
+//	for key, val := range snap.All() {
+//		report(key, val)
+//	}
+func (s *SafeMap[k, v]) Snapshot() *Snapshot[k, v] {
+	reply := s.send(operation[k, v]{op: "snapshot"})
+	return &Snapshot[k, v]{items: reply.items, order: reply.order}
+}
+
+// Get returns the value for the given key, or the zero value if the key was not present.
+func (s *Snapshot[k, v]) Get(key k) v {
+	return s.items[key]
+}
+
+// Lookup returns the value for the given key and whether it was present.
+func (s *Snapshot[k, v]) Lookup(key k) (val v, ok bool) {
+	val, ok = s.items[key]
+	return val, ok
+}
+
+// Exist reports whether the key was present.
+func (s *Snapshot[k, v]) Exist(key k) bool {
+	_, ok := s.items[key]
+	return ok
+}
+
+// Length returns the number of entries in the snapshot.
+func (s *Snapshot[k, v]) Length() int {
+	return len(s.items)
+}
+
+// Keys returns an iterator over the keys of the snapshot, in insertion order with WithInsertionOrder.
+func (s *Snapshot[k, v]) Keys() iter.Seq[k] {
+	if s.order != nil {
+		return slices.Values(s.order)
+	}
+	return maps.Keys(s.items)
+}
+
+// Values returns an iterator over the values of the snapshot, in insertion order with WithInsertionOrder.
+func (s *Snapshot[k, v]) Values() iter.Seq[v] {
+	if s.order != nil {
+		return func(yield func(v) bool) {
+			for _, key := range s.order {
+				if !yield(s.items[key]) {
+					return
+				}
+			}
+		}
+	}
+	return maps.Values(s.items)
+}
+
+// All returns an iterator over the entries of the snapshot, in insertion order with WithInsertionOrder.
+func (s *Snapshot[k, v]) All() iter.Seq2[k, v] {
+	if s.order != nil {
+		return func(yield func(k, v) bool) {
+			for _, key := range s.order {
+				if !yield(key, s.items[key]) {
+					return
+				}
+			}
+		}
+	}
+	return maps.All(s.items)
+}
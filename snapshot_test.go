@@ -0,0 +1,49 @@
+package safemap
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Snapshot(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":        nil,
+		"mutex":         {WithMutex[string, int]()},
+		"copyOnWrite":   {WithCopyOnWrite[string, int]()},
+		"snapshotReads": {WithSnapshotReads[string, int]()},
+		"backend":       {WithBackend[string, int](&boundedBackend{limit: 10, data: map[string]int{}})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+
+			m.Set("a", 1)
+			m.Set("b", 2)
+			snap := m.Snapshot()
+			again := m.Snapshot()
+
+			// the writes following the snapshot are not visible in it
+			m.Set("a", 10)
+			m.Delete("b")
+			m.Set("c", 3)
+
+			for _, s := range []*Snapshot[string, int]{snap, again} {
+				assert.Equal(t, 2, s.Length())
+				assert.Equal(t, 1, s.Get("a"))
+				assert.True(t, s.Exist("b"))
+				assert.False(t, s.Exist("c"))
+				val, ok := s.Lookup("b")
+				assert.True(t, ok)
+				assert.Equal(t, 2, val)
+				assert.Equal(t, []string{"a", "b"}, slices.Sorted(s.Keys()))
+				assert.Equal(t, []int{1, 2}, slices.Sorted(s.Values()))
+				assert.Equal(t, map[string]int{"a": 1, "b": 2}, maps.Collect(s.All()))
+			}
+			assert.Equal(t, map[string]int{"a": 10, "c": 3}, m.GetMap())
+			assert.Equal(t, map[string]int{"a": 10, "c": 3}, maps.Collect(m.Snapshot().All()))
+		})
+	}
+}
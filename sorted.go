@@ -0,0 +1,157 @@
+package safemap
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+)
+
+// SafeSortedMap is a SafeMap whose keys are kept sorted: Keys, Values, All and Snapshot iterate in ascending
+// order of the keys, and Range, Min and Max query the entries by key. It offers every method of SafeMap,
+// Range being replaced by the range query. A SafeSortedMap must be created with NewSafeSortedMap,
+// and must not be copied after first use.
+// example
+//
+//	events := NewSafeSortedMap[int64, Event]()
+//	events.Set(t.UnixNano(), event)
+//	for at, event := range events.Range(from.UnixNano(), to.UnixNano()) {
+//		fmt.Println(at, event)
+//	}
+type SafeSortedMap[k cmp.Ordered, v any] struct {
+	SafeMap[k, v]
+}
+
+type (
+	// sortedStore is implemented by sortedBackend, it lets the worker query a SafeSortedMap without the cmp.Ordered constraint.
+	sortedStore[k comparable, v any] interface {
+		Backend[k, v]
+		min() result[k, v]
+		max() result[k, v]
+		between(from, to k) result[k, v]
+	}
+
+	// sortedBackend is the Backend of a SafeSortedMap, a map along with its keys in ascending order.
+	// Inserting and deleting a key take a time linear in the number of keys, reads are those of a map.
+	sortedBackend[k cmp.Ordered, v any] struct {
+		items map[k]v
+		order []k
+	}
+)
+
+// NewSafeSortedMap creates a SafeSortedMap with the given options.
+// It panics if combined with WithBackend, WithCopyOnWrite, WithSnapshotReads or WithInsertionOrder.
+// Floating-point keys must not be NaN.
+// example
+//
+//	m := NewSafeSortedMap[string, int](WithDefaultTTL[string, int](time.Hour))
+func NewSafeSortedMap[k cmp.Ordered, v any](opts ...Option[k, v]) *SafeSortedMap[k, v] {
+	m := &SafeSortedMap[k, v]{}
+	m.configure(append(slices.Clip(opts), func(c *config[k, v]) {
+		c.newStore = func(capacity int) Backend[k, v] {
+			return &sortedBackend[k, v]{items: make(map[k]v, max(capacity, 0))}
+		}
+		c.kind = "SafeSortedMap"
+	}))
+	return m
+}
+
+// Range returns an iterator over the entries whose key is at least from and lower than to, in ascending order.
+// The entries are those present when Range is called, they are copied in a single operation.
+// example
+//
+//	for at, event := range events.Range(from.UnixNano(), to.UnixNano()) {
+//		fmt.Println(at, event)
+//	}
+func (m *SafeSortedMap[k, v]) Range(from, to k) iter.Seq2[k, v] {
+	reply := m.send(operation[k, v]{
+		op:  "between",
+		key: from,
+		to:  to,
+	})
+	return (&Snapshot[k, v]{items: reply.items, order: reply.order}).All()
+}
+
+// Min returns the entry with the lowest key. The ok result is false if the SafeSortedMap is empty.
+func (m *SafeSortedMap[k, v]) Min() (key k, val v, ok bool) {
+	reply := m.send(operation[k, v]{op: "min"})
+	return reply.key, reply.value, reply.ok
+}
+
+// Max returns the entry with the highest key. The ok result is false if the SafeSortedMap is empty.
+func (m *SafeSortedMap[k, v]) Max() (key k, val v, ok bool) {
+	reply := m.send(operation[k, v]{op: "max"})
+	return reply.key, reply.value, reply.ok
+}
+
+func (s *sortedBackend[k, v]) Get(key k) (v, bool) {
+	val, ok := s.items[key]
+	return val, ok
+}
+
+func (s *sortedBackend[k, v]) Set(key k, val v) {
+	if _, ok := s.items[key]; !ok {
+		i, _ := slices.BinarySearch(s.order, key)
+		s.order = slices.Insert(s.order, i, key)
+	}
+	s.items[key] = val
+}
+
+func (s *sortedBackend[k, v]) Delete(key k) {
+	if _, ok := s.items[key]; ok {
+		i, _ := slices.BinarySearch(s.order, key)
+		s.order = slices.Delete(s.order, i, i+1)
+		delete(s.items, key)
+	}
+}
+
+func (s *sortedBackend[k, v]) Len() int {
+	return len(s.items)
+}
+
+// All returns an iterator over the entries in ascending order of the keys.
+func (s *sortedBackend[k, v]) All() iter.Seq2[k, v] {
+	return func(yield func(k, v) bool) {
+		for _, key := range s.order {
+			if !yield(key, s.items[key]) {
+				return
+			}
+		}
+	}
+}
+
+// keys returns the keys in ascending order.
+func (s *sortedBackend[k, v]) keys() []k {
+	return slices.Clone(s.order)
+}
+
+// min returns the reply of the "min" operation.
+func (s *sortedBackend[k, v]) min() result[k, v] {
+	if len(s.order) == 0 {
+		return result[k, v]{}
+	}
+	key := s.order[0]
+	return result[k, v]{key: key, value: s.items[key], ok: true}
+}
+
+// max returns the reply of the "max" operation.
+func (s *sortedBackend[k, v]) max() result[k, v] {
+	if len(s.order) == 0 {
+		return result[k, v]{}
+	}
+	key := s.order[len(s.order)-1]
+	return result[k, v]{key: key, value: s.items[key], ok: true}
+}
+
+// between returns the reply of the "between" operation, a copy of the entries whose key is in [from, to).
+func (s *sortedBackend[k, v]) between(from, to k) result[k, v] {
+	lo, _ := slices.BinarySearch(s.order, from)
+	hi, _ := slices.BinarySearch(s.order, to)
+	hi = max(hi, lo)
+
+	order := slices.Clone(s.order[lo:hi])
+	items := make(map[k]v, len(order))
+	for _, key := range order {
+		items[key] = s.items[key]
+	}
+	return result[k, v]{items: items, order: order}
+}
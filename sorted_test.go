@@ -0,0 +1,66 @@
+package safemap
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeSortedMap(t *testing.T) {
+	m := NewSafeSortedMap[int, string]()
+	defer m.Close()
+
+	_, _, ok := m.Min()
+	assert.False(t, ok)
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < 100; i += 4 {
+				m.Set(i*10, "")
+			}
+		}()
+	}
+	wg.Wait()
+	m.Delete(500)
+	m.Set(10, "ten")
+
+	keys := slices.Collect(m.Keys())
+	assert.Len(t, keys, 99)
+	assert.True(t, slices.IsSorted(keys))
+
+	var ranged []int
+	for key := range m.Range(480, 530) {
+		ranged = append(ranged, key)
+	}
+	assert.Equal(t, []int{480, 490, 510, 520}, ranged, "from is included, to is excluded")
+	assert.Empty(t, maps.Collect(m.Range(530, 480)))
+	assert.Empty(t, maps.Collect(m.Range(2000, 3000)))
+
+	key, _, ok := m.Min()
+	assert.True(t, ok)
+	assert.Zero(t, key)
+	key, _, _ = m.Max()
+	assert.Equal(t, 990, key)
+
+	clone := m.Clone()
+	defer clone.Close()
+	clone.Set(-1, "")
+	assert.Equal(t, -1, slices.Collect(clone.Keys())[0], "the clone keeps the keys sorted")
+	assert.Equal(t, "ten", clone.Get(10))
+}
+
+func TestSafeSortedMap_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		NewSafeSortedMap(WithInsertionOrder[string, int]())
+	})
+
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	assert.Panics(t, func() { m.send(operation[string, int]{op: "min"}) })
+}
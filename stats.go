@@ -0,0 +1,135 @@
+package safemap
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+type (
+	// Stats holds the counters of the operations applied to a SafeMap since it was created.
+	Stats struct {
+		// Gets counts the keys looked up with Get, Lookup, GetMany, GetOrSet, GetVersioned and Apply,
+		// Hits the ones that were present and Misses the ones that were not.
+		Gets   uint64
+		Hits   uint64
+		Misses uint64
+
+		// Sets counts the values written, including the ones modified in place such as by Update or CompareAndSwap.
+		Sets uint64
+
+		// Deletes counts the entries removed explicitly, such as with Delete, DeleteFunc or Clear.
+		Deletes uint64
+
+		// Evictions counts the entries evicted by WithMaxEntries or WithMaxBytes,
+		// Expirations the entries removed because their TTL elapsed.
+		Evictions   uint64
+		Expirations uint64
+	}
+
+	// counters tracks the statistics of a SafeMap. They are updated atomically,
+	// since reads may be applied in parallel outside the worker.
+	counters struct {
+		hits, misses, sets       atomic.Uint64
+		deletes, evictions, exps atomic.Uint64
+	}
+)
+
+// HitRate returns the ratio of hits to lookups, between 0 and 1. It is 0 when no key was looked up.
+func (s Stats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Gets)
+}
+
+// add returns the sum of the counters of s and other.
+func (s Stats) add(other Stats) Stats {
+	return Stats{
+		Gets:        s.Gets + other.Gets,
+		Hits:        s.Hits + other.Hits,
+		Misses:      s.Misses + other.Misses,
+		Sets:        s.Sets + other.Sets,
+		Deletes:     s.Deletes + other.Deletes,
+		Evictions:   s.Evictions + other.Evictions,
+		Expirations: s.Expirations + other.Expirations,
+	}
+}
+
+// lookup counts a lookup of key, found reports whether it was present.
+func (c *counters) lookup(found bool) {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// removed counts an entry removed for reason.
+func (c *counters) removed(reason EvictionReason) {
+	switch reason {
+	case EvictionCapacity:
+		c.evictions.Add(1)
+	case EvictionExpired:
+		c.exps.Add(1)
+	case EvictionDeleted:
+		c.deletes.Add(1)
+	}
+}
+
+// read applies a read operation to store, counting the keys it looks up.
+func (c *core[k, v]) read(store Backend[k, v], op operation[k, v]) result[k, v] {
+	reply := applyRead(store, op)
+	switch op.op {
+	case "get", "lookup":
+		c.stats.lookup(reply.ok)
+	case "getMany":
+		c.stats.hits.Add(uint64(len(reply.items)))
+		c.stats.misses.Add(uint64(len(op.keys) - len(reply.items)))
+	}
+	return reply
+}
+
+// Stats returns the counters of the operations applied to the SafeMap since it was created.
+// The counters are read one by one while operations may be running, they are not a consistent snapshot.
+// example
+//
+//	stats := m.Stats()
+//	log.Printf("hit rate %.2f, %d evictions", stats.HitRate(), stats.Evictions)
+func (s *SafeMap[k, v]) Stats() Stats {
+	return s.load().stats.snapshot()
+}
+
+// snapshot reads the counters one by one.
+func (c *counters) snapshot() Stats {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	return Stats{
+		Gets:        hits + misses,
+		Hits:        hits,
+		Misses:      misses,
+		Sets:        c.sets.Load(),
+		Deletes:     c.deletes.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.exps.Load(),
+	}
+}
+
+// PublishExpvar publishes the metrics of the SafeMap with expvar under name, so they are served by /debug/vars.
+// The published variable is a JSON object holding the number of entries (length), the ratio of hits to lookups (hit_rate)
+// and the number of operations waiting for the worker (queue_depth, see QueueStats), evaluated every time it is read.
+// Like expvar.Publish, it panics if name is already in use. expvar can't unpublish a variable, the metrics of
+// a closed SafeMap remain published and report an empty map.
+// example
+//
+//	sessions := NewSafeMap[string, Session]()
+//	sessions.PublishExpvar("sessions")
+func (s *SafeMap[k, v]) PublishExpvar(name string) {
+	// the variable references the state rather than the handle, so it doesn't keep the SafeMap from being collected
+	c := s.load()
+	expvar.Publish(name, expvar.Func(func() any {
+		return map[string]any{
+			"length":      c.length(),
+			"hit_rate":    c.stats.snapshot().HitRate(),
+			"queue_depth": c.queueStats().Pending,
+		}
+	}))
+}
@@ -0,0 +1,91 @@
+package safemap
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Stats(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":   nil,
+		"mutex":    {WithMutex[string, int]()},
+		"readers":  {WithReadConcurrency[string, int](4)},
+		"snapshot": {WithSnapshotReads[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now), WithMaxEntries[string, int](3))...)
+
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Get("a")
+			m.Get("z")
+			m.Lookup("b")
+			m.GetMany("a", "b", "y")
+			m.GetOrSet("c", 3)
+			m.GetOrSet("c", 30)
+			m.Set("d", 4)
+			m.Delete("c")
+			m.Delete("c")
+			m.SetWithTTL("e", 5, time.Minute)
+			clock.Advance(time.Minute)
+			m.Exist("e")
+
+			stats := m.Stats()
+			assert.Equal(t, Stats{
+				Gets:        8,
+				Hits:        5,
+				Misses:      3,
+				Sets:        5,
+				Deletes:     1,
+				Evictions:   1,
+				Expirations: 1,
+			}, stats)
+			assert.InDelta(t, 0.625, stats.HitRate(), 1e-9)
+		})
+	}
+
+	assert.Zero(t, NewSafeMap[string, int]().Stats().HitRate())
+}
+
+func TestShardedSafeMap_Stats(t *testing.T) {
+	m := NewShardedSafeMap[int, int](4)
+	for i := range 10 {
+		m.Set(i, i)
+		m.Get(i)
+		m.Get(i + 10)
+	}
+	m.Delete(0)
+
+	assert.Equal(t, Stats{Gets: 20, Hits: 10, Misses: 10, Sets: 10, Deletes: 1}, m.Stats())
+}
+
+// expvarRuns counts the runs of TestSafeMap_PublishExpvar.
+var expvarRuns atomic.Int64
+
+func TestSafeMap_PublishExpvar(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	// expvar names are global, a unique name lets the test run several times
+	name := fmt.Sprint("safemap_test_", expvarRuns.Add(1))
+	m.PublishExpvar(name)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Get("a")
+	m.Get("z")
+
+	var metrics map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &metrics))
+	assert.Equal(t, map[string]any{"length": 2.0, "hit_rate": 0.5, "queue_depth": 0.0}, metrics)
+
+	assert.Panics(t, func() { m.PublishExpvar(name) })
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &metrics))
+	assert.Equal(t, 0.0, metrics["length"])
+}
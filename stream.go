@@ -0,0 +1,95 @@
+package safemap
+
+import "iter"
+
+// defaultStreamChunk is the number of entries read per operation by Stream and StreamKeys when the given size is not positive.
+const defaultStreamChunk = 1024
+
+// cursor is the state of an iteration by Stream, only used by the worker between the operations reading its chunks.
+type cursor[k comparable, v any] struct {
+	size int
+
+	// next and stop pull the entries from the backend, next is nil until the first chunk.
+	next func() (k, v, bool)
+	stop func()
+
+	// keys and vals hold the last chunk read, they are reused for every chunk.
+	keys []k
+	vals []v
+}
+
+// advance reads the next chunk of the cursor. The reply counts the entries of the chunk,
+// its ok result is false once the entries are exhausted.
+func (c *core[k, v]) advance(cur *cursor[k, v]) result[k, v] {
+	if cur.next == nil {
+		entries := c.store.All()
+		if _, ok := c.store.(mapBackend[k, v]); !ok {
+			// a Backend must not be modified while it is iterated, so its entries are copied
+			reply := applyRead(c.store, operation[k, v]{op: "getMap"})
+			entries = (&Snapshot[k, v]{items: reply.items, order: reply.order}).All()
+		}
+		cur.next, cur.stop = iter.Pull2(entries)
+	}
+
+	cur.keys, cur.vals = cur.keys[:0], cur.vals[:0]
+	for len(cur.keys) < cur.size {
+		key, val, ok := cur.next()
+		if !ok {
+			cur.stop()
+			return result[k, v]{n: len(cur.keys)}
+		}
+		cur.keys = append(cur.keys, key)
+		cur.vals = append(cur.vals, val)
+	}
+	return result[k, v]{n: len(cur.keys), ok: true}
+}
+
+// Stream returns an iterator over the entries of the SafeMap that reads them by chunks of size entries,
+// one operation per chunk, instead of copying the whole map like All. Memory stays bounded by the chunk,
+// and the writes of other goroutines go on between the chunks. A size lower than 1 reads 1024 entries per chunk.
+//
+// The iteration is not a snapshot, it has the semantics of ranging over a Go map modified in the loop:
+// no entry is yielded twice, an entry present during the whole iteration is yielded once, with a value it had
+// when its chunk was read, and an entry added or removed during the iteration may or may not be yielded.
+// With a custom Backend, whose entries can't be iterated while it is modified, the entries are copied on the first chunk.
+// example
+//
+//	for key, val := range m.Stream(10_000) {
+//		export(key, val)
+//	}
+func (s *SafeMap[k, v]) Stream(size int) iter.Seq2[k, v] {
+	if size < 1 {
+		size = defaultStreamChunk
+	}
+	return func(yield func(k, v) bool) {
+		cur := &cursor[k, v]{size: size}
+		done := false
+		defer func() {
+			if !done {
+				s.send(operation[k, v]{op: "streamStop", cursor: cur})
+			}
+		}()
+
+		for !done {
+			// a closed SafeMap replies with an empty chunk
+			reply := s.send(operation[k, v]{op: "streamNext", cursor: cur})
+			done = !reply.ok
+			for i, key := range cur.keys[:reply.n] {
+				if !yield(key, cur.vals[i]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// StreamKeys returns an iterator over the keys of the SafeMap that reads them by chunks of size keys, see Stream.
+func (s *SafeMap[k, v]) StreamKeys(size int) iter.Seq[k] {
+	return func(yield func(k) bool) {
+		for key := range s.Stream(size) {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package safemap
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Stream(t *testing.T) {
+	for name, opts := range map[string][]Option[int, int]{
+		"default":         nil,
+		"mutex":           {WithMutex[int, int]()},
+		"copy-on-write":   {WithCopyOnWrite[int, int]()},
+		"insertion order": {WithInsertionOrder[int, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+			for i := range 1000 {
+				m.Set(i, i)
+			}
+
+			assert.Equal(t, m.GetMap(), maps.Collect(m.Stream(64)))
+			assert.Len(t, slices.Collect(m.StreamKeys(0)), 1000)
+
+			// writes between the chunks neither block nor duplicate entries
+			seen := make(map[int]bool)
+			for key := range m.Stream(10) {
+				assert.False(t, seen[key], "yielded twice")
+				seen[key] = true
+				m.Delete(key)
+				m.Set(key+1000, key)
+				if len(seen) == 500 {
+					break
+				}
+			}
+			assert.Equal(t, 1000, m.Length())
+		})
+	}
+}
+
+func TestSafeMap_Stream_Closed(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	for i := range 100 {
+		m.Set(i, i)
+	}
+
+	n := 0
+	for range m.Stream(10) {
+		n++
+		if n == 15 {
+			m.Close()
+		}
+	}
+	assert.Equal(t, 20, n, "the chunk read before Close is finished")
+}
@@ -0,0 +1,39 @@
+package safemap
+
+// The methods in this file mirror the method set of sync.Map, so code written against it can switch to a
+// SafeMap by changing the declaration only. Swap, CompareAndSwap and CompareAndDelete already share their names.
+
+// Load returns the value stored for the given key, or the zero value if none, and whether it was present.
+// It is Lookup under the name used by sync.Map.
+func (s *SafeMap[k, v]) Load(key k) (value v, ok bool) {
+	return s.Lookup(key)
+}
+
+// Store sets the value for the given key. It is Set under the name used by sync.Map.
+func (s *SafeMap[k, v]) Store(key k, value v) {
+	s.Set(key, value)
+}
+
+// LoadOrStore returns the existing value for the given key if present. Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored. It is GetOrSet under the name used by sync.Map.
+func (s *SafeMap[k, v]) LoadOrStore(key k, value v) (actual v, loaded bool) {
+	return s.GetOrSet(key, value)
+}
+
+// LoadAndDelete deletes the value for the given key, returning the previous value if any.
+// The loaded result reports whether the key was present. It is GetAndDelete under the name used by sync.Map.
+func (s *SafeMap[k, v]) LoadAndDelete(key k) (value v, loaded bool) {
+	return s.GetAndDelete(key)
+}
+
+// Range calls f for each key and value in the SafeMap, stopping when f returns false. It is ForEach under the name
+// used by sync.Map: unlike sync.Map, the entries visited are those of a consistent Snapshot taken when Range is called.
+// example
+//
+//	m.Range(func(key string, val int) bool {
+//		fmt.Println(key, val)
+//		return true
+//	})
+func (s *SafeMap[k, v]) Range(f func(key k, value v) bool) {
+	s.ForEach(f)
+}
@@ -0,0 +1,58 @@
+package safemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_SyncMap(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+
+	m.Store("a", 1)
+	val, ok := m.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	_, ok = m.Load("b")
+	assert.False(t, ok)
+
+	actual, loaded := m.LoadOrStore("a", 2)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	actual, loaded = m.LoadOrStore("b", 2)
+	assert.False(t, loaded)
+	assert.Equal(t, 2, actual)
+
+	val, loaded = m.LoadAndDelete("b")
+	assert.True(t, loaded)
+	assert.Equal(t, 2, val)
+	_, loaded = m.LoadAndDelete("b")
+	assert.False(t, loaded)
+}
+
+func TestSafeMap_Range(t *testing.T) {
+	m := NewSafeMap[int, int]()
+	defer m.Close()
+	for i := range 10 {
+		m.Store(i, i)
+	}
+
+	// f may write the map without deadlocking, its writes are not visited
+	seen := 0
+	m.Range(func(key, val int) bool {
+		assert.Equal(t, key, val)
+		m.Store(key+100, val)
+		seen++
+		return true
+	})
+	assert.Equal(t, 10, seen)
+	assert.Equal(t, 20, m.Length())
+
+	seen = 0
+	m.Range(func(int, int) bool {
+		seen++
+		return seen < 3
+	})
+	assert.Equal(t, 3, seen)
+}
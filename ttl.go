@@ -0,0 +1,181 @@
+package safemap
+
+import (
+	"container/heap"
+	"time"
+)
+
+// noTTL is the TTL of entries stored without expiry, even when the SafeMap has a default TTL.
+const noTTL time.Duration = -1
+
+type (
+	// expiry is the deadline of a key stored with a TTL.
+	expiry[k comparable] struct {
+		key      k
+		ttl      time.Duration
+		deadline time.Time
+
+		// index is the position of the expiry in the heap.
+		index int
+	}
+
+	// expiries is a min-heap of the keys stored with a TTL, ordered by deadline and indexed by key.
+	// The zero value is empty and ready to use. It implements heap.Interface, use its set and delete methods instead.
+	expiries[k comparable] struct {
+		heap  []*expiry[k]
+		byKey map[k]*expiry[k]
+	}
+)
+
+// Len implements heap.Interface.
+func (e *expiries[k]) Len() int {
+	return len(e.heap)
+}
+
+// Less implements heap.Interface.
+func (e *expiries[k]) Less(i, j int) bool {
+	return e.heap[i].deadline.Before(e.heap[j].deadline)
+}
+
+// Swap implements heap.Interface.
+func (e *expiries[k]) Swap(i, j int) {
+	e.heap[i], e.heap[j] = e.heap[j], e.heap[i]
+	e.heap[i].index = i
+	e.heap[j].index = j
+}
+
+// Push implements heap.Interface.
+func (e *expiries[k]) Push(x any) {
+	exp := x.(*expiry[k])
+	exp.index = len(e.heap)
+	e.heap = append(e.heap, exp)
+}
+
+// Pop implements heap.Interface.
+func (e *expiries[k]) Pop() any {
+	last := len(e.heap) - 1
+	exp := e.heap[last]
+	e.heap[last] = nil
+	e.heap = e.heap[:last]
+	return exp
+}
+
+// set makes key expire at deadline, ttl is the lifetime it was given.
+func (e *expiries[k]) set(key k, ttl time.Duration, deadline time.Time) {
+	if exp, ok := e.byKey[key]; ok {
+		exp.ttl = ttl
+		exp.deadline = deadline
+		heap.Fix(e, exp.index)
+		return
+	}
+
+	if e.byKey == nil {
+		e.byKey = make(map[k]*expiry[k])
+	}
+	exp := &expiry[k]{key: key, ttl: ttl, deadline: deadline}
+	e.byKey[key] = exp
+	heap.Push(e, exp)
+}
+
+// delete removes the deadline of key, if any.
+func (e *expiries[k]) delete(key k) {
+	if exp, ok := e.byKey[key]; ok {
+		heap.Remove(e, exp.index)
+		delete(e.byKey, key)
+	}
+}
+
+// next returns the key with the earliest deadline.
+func (e *expiries[k]) next() (*expiry[k], bool) {
+	if len(e.heap) == 0 {
+		return nil, false
+	}
+	return e.heap[0], true
+}
+
+// list returns a copy of every expiry.
+func (e *expiries[k]) list() []expiry[k] {
+	list := make([]expiry[k], len(e.heap))
+	for i, exp := range e.heap {
+		list[i] = *exp
+	}
+	return list
+}
+
+// janitor periodically removes the expired entries until the SafeMap is closed.
+// It sends a sweep operation to the worker instead of touching the entries itself, and only while some entry has a TTL.
+func (c *core[k, v]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if c.hasExpiries.Load() {
+				c.sendAsync(operation[k, v]{op: "sweep"})
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// SetWithTTL sets the value for the given key in the SafeMap, the entry expires once ttl has elapsed.
+// An expired entry is never observed again, as if it had been deleted.
+// A ttl lower than or equal to zero stores the entry without expiry, even when the SafeMap has a default TTL.
+// Writing the key again with Set, Swap, SetMany, SetIfAbsent or GetOrSet replaces its TTL,
+// while methods modifying the current value such as Update, Upsert, CompareAndSwap, SetIfPresent and TransformValues keep it.
+// example
+//
+//	m := NewSafeMap[string, Session]()
+//	m.SetWithTTL("session-1", session, 30*time.Minute)
+func (s *SafeMap[k, v]) SetWithTTL(key k, val v, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = noTTL
+	}
+	s.send(operation[k, v]{
+		op:    "set",
+		key:   key,
+		value: val,
+		ttl:   ttl,
+	})
+}
+
+// GetTTL returns the remaining lifetime of the entry for the given key.
+// The ok result reports whether the key is present, the remaining lifetime is zero for an entry without expiry.
+// example
+//
+//	if ttl, ok := m.GetTTL("token"); ok && ttl > 0 && ttl < time.Minute {
+//		refresh("token")
+//	}
+func (s *SafeMap[k, v]) GetTTL(key k) (ttl time.Duration, ok bool) {
+	reply := s.send(operation[k, v]{
+		op:  "getTTL",
+		key: key,
+	})
+	return reply.ttl, reply.ok
+}
+
+// Touch renews the lifetime of the entry for the given key, which then expires once its TTL has elapsed again.
+// It reports whether the key is present, an entry without expiry is left untouched.
+// example
+//
+//	if !m.Touch(sessionID) {
+//		return errSessionExpired
+//	}
+func (s *SafeMap[k, v]) Touch(key k) bool {
+	return s.send(operation[k, v]{
+		op:  "touch",
+		key: key,
+	}).ok
+}
+
+// Expire changes the TTL of the entry for the given key without rewriting its value, it then expires once ttl has elapsed.
+// A ttl lower than or equal to zero removes the expiry of the entry. Expire reports whether the key is present.
+func (s *SafeMap[k, v]) Expire(key k, ttl time.Duration) bool {
+	return s.send(operation[k, v]{
+		op:  "expire",
+		key: key,
+		ttl: ttl,
+	}).ok
+}
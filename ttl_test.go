@@ -0,0 +1,316 @@
+package safemap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock only moving forward when advanced by the test.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSafeMap_SetWithTTL(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":   nil,
+		"mutex":    {WithMutex[string, int]()},
+		"readers":  {WithReadConcurrency[string, int](4)},
+		"snapshot": {WithSnapshotReads[string, int](), WithCopyOnWrite[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now))...)
+
+			m.SetWithTTL("a", 1, time.Minute)
+			m.SetWithTTL("b", 2, 2*time.Minute)
+			m.SetWithTTL("c", 3, 0)
+			m.Set("d", 4)
+
+			clock.Advance(time.Minute - time.Nanosecond)
+			assert.Equal(t, 1, m.Get("a"))
+			assert.Equal(t, 4, m.Length())
+
+			clock.Advance(time.Nanosecond)
+			assert.False(t, m.Exist("a"))
+			assert.Equal(t, 3, m.Length())
+			assert.Equal(t, map[string]int{"b": 2, "c": 3, "d": 4}, m.GetMap())
+
+			// writing the value again replaces the TTL, modifying it keeps the TTL
+			m.Set("b", 20)
+			m.SetWithTTL("c", 30, time.Minute)
+			m.Update("c", func(old int, exists bool) (int, bool) { return old + 1, true })
+			clock.Advance(time.Hour)
+			assert.Equal(t, 20, m.Get("b"))
+			_, ok := m.Lookup("c")
+			assert.False(t, ok)
+
+			// the last expiry gone, reads go back to their fast path
+			assert.False(t, m.hasExpiries.Load())
+			val, err := m.GetCtx(context.Background(), "d")
+			assert.NoError(t, err)
+			assert.Equal(t, 4, val)
+		})
+	}
+}
+
+func TestWithDefaultTTL(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeMap(WithDefaultTTL[string, int](time.Minute), withClock[string, int](clock.Now))
+
+	m.Set("set", 1)
+	m.SetWithTTL("long", 1, time.Hour)
+	m.SetWithTTL("forever", 1, 0)
+	m.Update("update", func(int, bool) (int, bool) { return 1, true })
+	m.Upsert("upsert", 1, nil)
+	m.SetMany(map[string]int{"many": 1})
+
+	clock.Advance(30 * time.Second)
+	// renewing a key restarts its default TTL
+	m.Set("set", 2)
+	m.Update("update", func(old int, _ bool) (int, bool) { return old + 1, true })
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, map[string]int{"set": 2, "long": 1, "forever": 1}, m.GetMap())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, map[string]int{"forever": 1}, m.GetMap())
+}
+
+func TestSafeMap_GetTTL(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeMap(withClock[string, int](clock.Now))
+
+	m.SetWithTTL("a", 1, time.Minute)
+	m.Set("b", 2)
+
+	clock.Advance(20 * time.Second)
+	ttl, ok := m.GetTTL("a")
+	assert.True(t, ok)
+	assert.Equal(t, 40*time.Second, ttl)
+
+	ttl, ok = m.GetTTL("b")
+	assert.True(t, ok)
+	assert.Zero(t, ttl)
+
+	clock.Advance(time.Minute)
+	_, ok = m.GetTTL("a")
+	assert.False(t, ok)
+	_, ok = m.GetTTL("missing")
+	assert.False(t, ok)
+}
+
+func TestSafeMap_TouchExpire(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeMap(withClock[string, int](clock.Now))
+
+	m.SetWithTTL("a", 1, time.Minute)
+	m.Set("b", 2)
+
+	clock.Advance(50 * time.Second)
+	assert.True(t, m.Touch("a"))
+	ttl, _ := m.GetTTL("a")
+	assert.Equal(t, time.Minute, ttl)
+
+	assert.True(t, m.Touch("b"))
+	ttl, _ = m.GetTTL("b")
+	assert.Zero(t, ttl)
+
+	assert.True(t, m.Expire("b", time.Second))
+	assert.True(t, m.Expire("a", 0))
+	assert.False(t, m.Expire("missing", time.Second))
+	assert.False(t, m.Touch("missing"))
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, map[string]int{"a": 1}, m.GetMap())
+	assert.False(t, m.hasExpiries.Load())
+
+	// the TTL given by Expire is renewed by Touch, also on a clone
+	m.Expire("a", time.Minute)
+	clone := m.Clone()
+	clock.Advance(30 * time.Second)
+	assert.True(t, clone.Touch("a"))
+	clock.Advance(45 * time.Second)
+	assert.True(t, clone.Exist("a"))
+	assert.False(t, m.Exist("a"))
+}
+
+func TestWithSlidingExpiration(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":  nil,
+		"mutex":   {WithMutex[string, int]()},
+		"readers": {WithReadConcurrency[string, int](4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now), WithSlidingExpiration[string, int]())...)
+
+			m.SetWithTTL("a", 1, time.Minute)
+			m.SetWithTTL("b", 2, time.Minute)
+			m.SetWithTTL("c", 3, time.Minute)
+			m.Set("d", 4)
+
+			// reading a value renews its TTL, checking its presence doesn't
+			clock.Advance(50 * time.Second)
+			assert.Equal(t, 1, m.Get("a"))
+			assert.Equal(t, map[string]int{"b": 2, "d": 4}, m.GetMany("b", "d"))
+			assert.True(t, m.Exist("c"))
+
+			clock.Advance(50 * time.Second)
+			_, ok := m.Lookup("a")
+			assert.True(t, ok)
+			assert.Equal(t, map[string]int{"a": 1, "b": 2, "d": 4}, m.GetMap())
+
+			ttl, _ := m.GetTTL("a")
+			assert.Equal(t, time.Minute, ttl)
+			ttl, _ = m.GetTTL("b")
+			assert.Equal(t, 10*time.Second, ttl)
+
+			clock.Advance(time.Minute)
+			assert.Equal(t, map[string]int{"d": 4}, m.GetMap())
+		})
+	}
+}
+
+func TestWithCleanupInterval(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now), WithCleanupInterval[string, int](time.Millisecond))...)
+			defer m.Close()
+
+			stored := func() int {
+				m.mu.RLock()
+				defer m.mu.RUnlock()
+				return m.store.Len()
+			}
+
+			m.SetWithTTL("a", 1, time.Minute)
+			m.Set("b", 2)
+			time.Sleep(10 * time.Millisecond)
+			assert.Equal(t, 2, stored())
+
+			// the expired entry is removed without any operation on the map
+			clock.Advance(time.Minute)
+			assert.Eventually(t, func() bool { return stored() == 1 }, time.Second, time.Millisecond)
+			assert.False(t, m.hasExpiries.Load())
+		})
+	}
+}
+
+func TestWithOnExpire(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				expired []string
+			)
+			release := make(chan struct{})
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now), WithOnExpire(func(key string, val int) {
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				expired = append(expired, fmt.Sprint(key, "=", val))
+			}))...)
+			defer m.Close()
+
+			m.SetWithTTL("a", 1, time.Minute)
+			m.SetWithTTL("b", 2, 2*time.Minute)
+			m.SetWithTTL("c", 3, time.Minute)
+			m.Delete("c")
+			m.Set("d", 4)
+
+			// a blocked callback doesn't hold up the map
+			clock.Advance(2 * time.Minute)
+			assert.Equal(t, map[string]int{"d": 4}, m.GetMap())
+			m.Set("e", 5)
+
+			close(release)
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(expired) == 2
+			}, time.Second, time.Millisecond)
+			assert.Equal(t, []string{"a=1", "b=2"}, expired)
+		})
+	}
+}
+
+func TestSafeMap_SetWithTTLClone(t *testing.T) {
+	clock := newFakeClock()
+	m := NewSafeMap(withClock[string, int](clock.Now))
+
+	m.SetWithTTL("a", 1, time.Minute)
+	m.Set("b", 2)
+	clone := m.Clone()
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, map[string]int{"b": 2}, clone.GetMap())
+	assert.Equal(t, map[string]int{"b": 2}, m.GetMap())
+}
+
+func TestSafeMap_CloneWithCleanupInterval(t *testing.T) {
+	m := NewSafeMap(WithCleanupInterval[int, int](time.Microsecond), WithMaxBytes(1<<30, func(int, int) int { return 8 }))
+	defer m.Close()
+	for i := range 10_000 {
+		m.SetWithTTL(i, i, time.Minute)
+	}
+
+	// the janitor of the clone starts once its entries, TTLs and sizes are in place
+	for range 5 {
+		clone := m.Clone()
+		assert.Equal(t, 10_000, clone.Length())
+		assert.Equal(t, int64(80_000), clone.SizeBytes())
+		ttl, ok := clone.GetTTL(1)
+		assert.True(t, ok)
+		assert.Positive(t, ttl)
+		assert.NoError(t, clone.Close())
+	}
+}
+
+func TestExpiries(t *testing.T) {
+	var e expiries[int]
+	base := time.Now()
+
+	for i, offset := range []int{5, 3, 8, 1, 9} {
+		e.set(i, time.Duration(offset), base.Add(time.Duration(offset)))
+	}
+	e.set(2, 0, base)
+	e.delete(3)
+
+	var order []int
+	for exp, ok := e.next(); ok; exp, ok = e.next() {
+		order = append(order, exp.key)
+		e.delete(exp.key)
+	}
+	assert.Equal(t, []int{2, 1, 0, 4}, order)
+	assert.Empty(t, e.byKey)
+}
@@ -0,0 +1,197 @@
+package safemap
+
+type (
+	// Tx is the view of the SafeMap given to the function run by Txn. Reads see the writes made earlier in the transaction,
+	// and the writes only become visible to others once the transaction commits.
+	Tx[k comparable, v any] interface {
+		// Get returns the value for the given key and whether it is present.
+		Get(key k) (v, bool)
+
+		// Set sets the value for the given key.
+		Set(key k, val v)
+
+		// Delete removes the given key.
+		Delete(key k)
+	}
+
+	// OpKind is the kind of an operation applied by SafeMap.Apply.
+	OpKind int
+
+	// Op is an operation applied by SafeMap.Apply, build it with GetOp, SetOp or DeleteOp.
+	Op[k comparable, v any] struct {
+		Kind  OpKind
+		Key   k
+		Value v
+	}
+
+	// Result is the outcome of an Op. For an OpGet, Value is the value read and OK reports whether the key was present.
+	// For an OpDelete, OK reports whether the key was present. For an OpSet, OK is always true.
+	Result[v any] struct {
+		Value v
+		OK    bool
+	}
+
+	// txn records the writes of a transaction until it commits.
+	txn[k comparable, v any] struct {
+		store Backend[k, v]
+
+		// writes holds the writes in order, latest the index of the last write of every key.
+		writes []txWrite[k, v]
+		latest map[k]int
+	}
+
+	// txWrite is a write of a transaction, deleted reports whether the key was removed.
+	txWrite[k comparable, v any] struct {
+		key     k
+		value   v
+		deleted bool
+	}
+)
+
+const (
+	// OpGet reads the value of the key.
+	OpGet OpKind = iota + 1
+	// OpSet sets the value of the key, like Set.
+	OpSet
+	// OpDelete removes the key, like Delete.
+	OpDelete
+)
+
+// GetOp returns an Op reading the value of key.
+func GetOp[k comparable, v any](key k) Op[k, v] {
+	return Op[k, v]{Kind: OpGet, Key: key}
+}
+
+// SetOp returns an Op setting the value of key to val.
+func SetOp[k comparable, v any](key k, val v) Op[k, v] {
+	return Op[k, v]{Kind: OpSet, Key: key, Value: val}
+}
+
+// DeleteOp returns an Op removing key.
+func DeleteOp[k comparable, v any](key k) Op[k, v] {
+	return Op[k, v]{Kind: OpDelete, Key: key}
+}
+
+func (t *txn[k, v]) Get(key k) (v, bool) {
+	if i, ok := t.latest[key]; ok {
+		w := t.writes[i]
+		if w.deleted {
+			var zero v
+			return zero, false
+		}
+		return w.value, true
+	}
+	return t.store.Get(key)
+}
+
+func (t *txn[k, v]) Set(key k, val v) {
+	t.write(txWrite[k, v]{key: key, value: val})
+}
+
+func (t *txn[k, v]) Delete(key k) {
+	t.write(txWrite[k, v]{key: key, deleted: true})
+}
+
+// write records w as the latest write of its key.
+func (t *txn[k, v]) write(w txWrite[k, v]) {
+	if t.latest == nil {
+		t.latest = make(map[k]int)
+	}
+	t.latest[w.key] = len(t.writes)
+	t.writes = append(t.writes, w)
+}
+
+// transact runs fn and applies its writes in order if it returns nil. The caller must hold the write lock.
+// A panic in fn leaves the entries untouched as well, since nothing is applied before fn returns.
+func (c *core[k, v]) transact(fn func(tx Tx[k, v]) error) error {
+	tx := &txn[k, v]{store: c.store}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	for _, w := range tx.writes {
+		switch {
+		case w.deleted:
+			c.remove(w.key)
+		case c.exists(w.key):
+			c.put(w.key, w.value)
+		default:
+			c.set(w.key, w.value, 0)
+		}
+	}
+	return nil
+}
+
+// exists reports whether key is present, the caller must hold the lock.
+func (c *core[k, v]) exists(key k) bool {
+	_, ok := c.store.Get(key)
+	return ok
+}
+
+// Txn runs fn with a transaction on the SafeMap and commits its writes atomically if it returns nil:
+// no other operation observes the entries between two writes of the transaction.
+// If fn returns an error or panics, the writes are discarded and the error is returned, or the panic raised again.
+//
+// fn runs inside the worker, or under the lock with WithMutex, so it blocks every other operation while it runs.
+// It must be quick and must not call the methods of the SafeMap, which would deadlock, nor keep tx after it returns.
+// An existing entry keeps its TTL, and a new one gets the default TTL.
+// example
+//
+//	err := m.Txn(func(tx safemap.Tx[string, int]) error {
+//		from, _ := tx.Get("alice")
+//		if from < 10 {
+//			return errInsufficientFunds
+//		}
+//		to, _ := tx.Get("bob")
+//		tx.Set("alice", from-10)
+//		tx.Set("bob", to+10)
+//		return nil
+//	})
+func (s *SafeMap[k, v]) Txn(fn func(tx Tx[k, v]) error) error {
+	return s.send(operation[k, v]{
+		op:  "txn",
+		txn: fn,
+	}).err
+}
+
+// applyOps applies ops in order, the caller must hold the write lock.
+func (c *core[k, v]) applyOps(ops []Op[k, v]) []Result[v] {
+	results := make([]Result[v], len(ops))
+	for i, op := range ops {
+		switch op.Kind {
+		case OpGet:
+			val, ok := c.store.Get(op.Key)
+			c.stats.lookup(ok)
+			if ok {
+				c.access(op.Key)
+			}
+			results[i] = Result[v]{Value: val, OK: ok}
+		case OpSet:
+			c.set(op.Key, op.Value, 0)
+			results[i] = Result[v]{OK: true}
+		case OpDelete:
+			ok := c.exists(op.Key)
+			c.remove(op.Key)
+			results[i] = Result[v]{OK: ok}
+		}
+	}
+	return results
+}
+
+// Apply applies ops in order as a single atomic operation: other operations observe either none or all of the writes,
+// and the reads see the entries as left by the previous ops. It returns the result of every op, in the same order.
+// Unlike Txn, the ops can't depend on the values read, but they don't run any code inside the worker.
+// example
+//
+//	results := m.Apply([]safemap.Op[string, int]{
+//		safemap.GetOp[string, int]("a"),
+//		safemap.DeleteOp[string, int]("a"),
+//		safemap.SetOp("b", 2),
+//	})
+//	fmt.Println(results[0].Value, results[1].OK)
+func (s *SafeMap[k, v]) Apply(ops []Op[k, v]) []Result[v] {
+	return s.send(operation[k, v]{
+		op:  "applyOps",
+		ops: ops,
+	}).results
+}
@@ -0,0 +1,124 @@
+package safemap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Txn(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+			m.Set("a", 1)
+			m.Set("b", 2)
+
+			err := m.Txn(func(tx Tx[string, int]) error {
+				a, _ := tx.Get("a")
+				tx.Set("c", a)
+				tx.Delete("a")
+
+				// reads see the earlier writes of the transaction
+				_, ok := tx.Get("a")
+				assert.False(t, ok)
+				c, ok := tx.Get("c")
+				assert.True(t, ok)
+				assert.Equal(t, 1, c)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]int{"b": 2, "c": 1}, m.GetMap())
+
+			// an error discards the writes
+			errAbort := errors.New("abort")
+			err = m.Txn(func(tx Tx[string, int]) error {
+				tx.Set("b", 20)
+				tx.Delete("c")
+				return errAbort
+			})
+			assert.ErrorIs(t, err, errAbort)
+			assert.Equal(t, map[string]int{"b": 2, "c": 1}, m.GetMap())
+
+			// so does a panic, which is raised in the caller
+			assert.PanicsWithValue(t, "boom", func() {
+				_ = m.Txn(func(tx Tx[string, int]) error {
+					tx.Set("b", 20)
+					panic("boom")
+				})
+			})
+			assert.Equal(t, map[string]int{"b": 2, "c": 1}, m.GetMap())
+		})
+	}
+}
+
+func TestSafeMap_Txn_Atomic(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+	m.Set("from", 100)
+	m.Set("to", 0)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				_ = m.Txn(func(tx Tx[string, int]) error {
+					from, _ := tx.Get("from")
+					to, _ := tx.Get("to")
+					tx.Set("from", from-1)
+					tx.Set("to", to+1)
+					return nil
+				})
+			}
+		}()
+	}
+
+	// observers never see a value moved out of one key and not yet into the other
+	for range 100 {
+		items := m.GetMap()
+		assert.Equal(t, 100, items["from"]+items["to"])
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"from": 0, "to": 100}, m.GetMap())
+}
+
+func TestSafeMap_Apply(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+			m.Set("a", 1)
+
+			results := m.Apply([]Op[string, int]{
+				GetOp[string, int]("a"),
+				SetOp("b", 2),
+				DeleteOp[string, int]("a"),
+				GetOp[string, int]("a"),
+				GetOp[string, int]("b"),
+				DeleteOp[string, int]("z"),
+				{Kind: OpSet, Key: "c", Value: 3},
+			})
+			assert.Equal(t, []Result[int]{
+				{Value: 1, OK: true},
+				{OK: true},
+				{OK: true},
+				{},
+				{Value: 2, OK: true},
+				{},
+				{OK: true},
+			}, results)
+			assert.Equal(t, map[string]int{"b": 2, "c": 3}, m.GetMap())
+			assert.Empty(t, m.Apply(nil))
+		})
+	}
+}
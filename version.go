@@ -0,0 +1,95 @@
+package safemap
+
+// versionOf returns the version of key and whether it is present, the caller must hold the write lock.
+// Versions are only tracked once asked for: an entry written before gets its version on the first call.
+func (c *core[k, v]) versionOf(key k) (uint64, bool) {
+	if _, ok := c.store.Get(key); !ok {
+		return 0, false
+	}
+	if c.versions == nil {
+		c.versions = make(map[k]uint64)
+	}
+	ver, ok := c.versions[key]
+	if !ok {
+		c.version++
+		ver = c.version
+		c.versions[key] = ver
+	}
+	return ver, true
+}
+
+// bump gives a new version to key after it was written, the caller must hold the write lock.
+func (c *core[k, v]) bump(key k) {
+	if c.versions != nil {
+		c.version++
+		c.versions[key] = c.version
+	}
+}
+
+// GetVersioned retrieves the value for the given key along with its version.
+// The version increases with every write of the key, including after it was deleted and set again,
+// so a version that changed between two calls reveals a concurrent modification.
+// The ok result reports whether the key exists, the version is 0 when it doesn't.
+// example
+//
+//	cfg, ver, ok := m.GetVersioned("config")
+//	// ... act on cfg
+//	if _, now, _ := m.GetVersioned("config"); now != ver {
+//		// the entry was modified in the meantime
+//	}
+func (s *SafeMap[k, v]) GetVersioned(key k) (val v, version uint64, ok bool) {
+	reply := s.send(operation[k, v]{
+		op:  "getVersioned",
+		key: key,
+	})
+	return reply.value, reply.version, reply.ok
+}
+
+// SetIfVersion sets the value for the given key only if its version is still expectedVersion, as returned by GetVersioned,
+// and reports whether it did. An expectedVersion of 0 sets the value only if the key is absent.
+// It implements optimistic concurrency for values that can't be compared, where CompareAndSwap doesn't apply.
+// example
+//
+//	for {
+//		cfg, ver, _ := m.GetVersioned("config")
+//		if m.SetIfVersion("config", cfg.With(change), ver) {
+//			break
+//		}
+//	}
+func (s *SafeMap[k, v]) SetIfVersion(key k, val v, expectedVersion uint64) bool {
+	return s.send(operation[k, v]{
+		op:      "setIfVersion",
+		key:     key,
+		value:   val,
+		version: expectedVersion,
+	}).ok
+}
+
+// UpdateWithRetry updates the value for the given key with fn, like Update, but runs fn outside the worker
+// so a slow fn doesn't block the other operations. fn receives the current value and whether the key exists,
+// and returns the new value and whether to keep it, the key being deleted otherwise.
+// The write only applies if the key wasn't modified since it was read, see SetIfVersion, otherwise fn runs again
+// on the new value. ErrConflict is returned once attempts tries, at least one, all conflicted.
+// example
+//
+//	err := m.UpdateWithRetry("config", 5, func(cfg Config, exists bool) (Config, bool) {
+//		return cfg.With(change), true
+//	})
+func (s *SafeMap[k, v]) UpdateWithRetry(key k, attempts int, fn func(old v, exists bool) (new v, keep bool)) error {
+	for range max(attempts, 1) {
+		old, ver, exists := s.GetVersioned(key)
+		val, keep := fn(old, exists)
+
+		op := operation[k, v]{op: "setIfVersion", key: key, value: val, version: ver}
+		if !keep {
+			if !exists {
+				return nil
+			}
+			op = operation[k, v]{op: "deleteIfVersion", key: key, version: ver}
+		}
+		if s.send(op).ok {
+			return nil
+		}
+	}
+	return ErrConflict
+}
@@ -0,0 +1,118 @@
+package safemap
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_GetVersioned(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker":     nil,
+		"mutex":      {WithMutex[string, int]()},
+		"maxEntries": {WithMaxEntries[string, int](1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewSafeMap(opts...)
+			defer m.Close()
+
+			_, ver, ok := m.GetVersioned("a")
+			assert.False(t, ok)
+			assert.Zero(t, ver)
+
+			// an entry written before versions were asked for gets one on the first call
+			m.Set("a", 1)
+			val, first, ok := m.GetVersioned("a")
+			assert.True(t, ok)
+			assert.Equal(t, 1, val)
+			assert.NotZero(t, first)
+			_, ver, _ = m.GetVersioned("a")
+			assert.Equal(t, first, ver, "reading keeps the version")
+
+			m.Update("a", func(old int, exists bool) (int, bool) { return old + 1, true })
+			val, second, _ := m.GetVersioned("a")
+			assert.Equal(t, 2, val)
+			assert.Greater(t, second, first)
+
+			// a key deleted and set again never reuses a version
+			m.Delete("a")
+			_, ver, ok = m.GetVersioned("a")
+			assert.False(t, ok)
+			assert.Zero(t, ver)
+			m.Set("a", 2)
+			_, third, _ := m.GetVersioned("a")
+			assert.Greater(t, third, second)
+
+			assert.Equal(t, uint64(6), m.Stats().Gets)
+		})
+	}
+}
+
+func TestSafeMap_SetIfVersion(t *testing.T) {
+	m := NewSafeMap[string, []int]()
+	defer m.Close()
+
+	// version 0 stands for an absent key
+	assert.True(t, m.SetIfVersion("a", []int{1}, 0))
+	assert.False(t, m.SetIfVersion("a", []int{2}, 0))
+
+	val, ver, _ := m.GetVersioned("a")
+	assert.Equal(t, []int{1}, val)
+	assert.True(t, m.SetIfVersion("a", append(val, 2), ver))
+	assert.False(t, m.SetIfVersion("a", []int{3}, ver), "the version changed with the write")
+	assert.Equal(t, []int{1, 2}, m.Get("a"))
+
+	// concurrent writers retry until their change applies on the latest version
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				val, ver, _ := m.GetVersioned("a")
+				if m.SetIfVersion("a", append(slices.Clone(val), i), ver) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, m.Get("a"), 12)
+}
+
+func TestSafeMap_UpdateWithRetry(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := m.UpdateWithRetry("a", 1000, func(old int, exists bool) (int, bool) {
+				return old + 1, true
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, m.Get("a"))
+
+	// not keeping the value deletes the key
+	assert.NoError(t, m.UpdateWithRetry("a", 1, func(old int, exists bool) (int, bool) { return 0, false }))
+	assert.False(t, m.Exist("a"))
+	assert.NoError(t, m.UpdateWithRetry("a", 1, func(old int, exists bool) (int, bool) { return 0, false }))
+
+	// a key modified during every attempt fails with ErrConflict
+	calls := 0
+	err := m.UpdateWithRetry("b", 3, func(old int, exists bool) (int, bool) {
+		calls++
+		m.Set("b", calls)
+		return old + 1, true
+	})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, m.Get("b"))
+}
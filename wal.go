@@ -0,0 +1,232 @@
+package safemap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The kinds of the records of the write-ahead log.
+const (
+	walSet byte = iota + 1
+	walDelete
+)
+
+// walRecord is a mutation read back from the write-ahead log.
+type walRecord[k comparable, v any] struct {
+	key      k
+	value    v
+	deadline int64
+	deleted  bool
+}
+
+// logSet appends a record of key set to val to the pending records of the write-ahead log,
+// along with the deadline of its TTL if it has one. The caller must hold the write lock.
+func (c *core[k, v]) logSet(key k, val v) {
+	var deadline int64
+	if exp, ok := c.expiries.byKey[key]; ok {
+		deadline = exp.deadline.UnixNano()
+	}
+
+	keys, values := c.codecs()
+	buf := append(c.walBuf, walSet)
+	buf, err := appendCoded(buf, keys, key)
+	if err == nil {
+		buf, err = appendCoded(buf, values, val)
+	}
+	if err != nil {
+		c.failWAL(err)
+		return
+	}
+	c.walBuf = binary.AppendVarint(buf, deadline)
+}
+
+// logDelete appends a record of key deleted to the pending records of the write-ahead log. The caller must hold the write lock.
+func (c *core[k, v]) logDelete(key k) {
+	keys, _ := c.codecs()
+	buf, err := appendCoded(append(c.walBuf, walDelete), keys, key)
+	if err != nil {
+		c.failWAL(err)
+		return
+	}
+	c.walBuf = buf
+}
+
+// appendCoded appends val encoded by codec to buf, prefixed by its length.
+func appendCoded[T any](buf []byte, codec Codec[T], val T) ([]byte, error) {
+	field, err := codec.Append(nil, val)
+	if err != nil {
+		return buf, err
+	}
+	return appendField(buf, field), nil
+}
+
+// flushWAL writes the pending records to the write-ahead log, and syncs it if it has a Sync method such as *os.File.
+// It is called once per batch of operations, before replying to them. The caller must hold the write lock.
+func (c *core[k, v]) flushWAL() {
+	if len(c.walBuf) == 0 {
+		return
+	}
+	defer func() {
+		c.walBuf = c.walBuf[:0]
+	}()
+	if c.walErr.Load() != nil {
+		return
+	}
+
+	if _, err := c.cfg.wal.Write(c.walBuf); err != nil {
+		c.failWAL(err)
+		return
+	}
+	if syncer, ok := c.cfg.wal.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			c.failWAL(err)
+		}
+	}
+}
+
+// lockedWriter is the write-ahead log shared by the shards of a ShardedSafeMap. Each shard writes the records
+// of a batch in a single call, which is serialized with the ones of the other shards so batches never interleave.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// Sync syncs the log if it has a Sync method, see WithWAL.
+func (l *lockedWriter) Sync() error {
+	syncer, ok := l.w.(interface{ Sync() error })
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return syncer.Sync()
+}
+
+// failWAL records the first error of the write-ahead log, nothing is written to it afterwards.
+func (c *core[k, v]) failWAL(err error) {
+	err = fmt.Errorf("safemap: write-ahead log: %w", err)
+	c.walErr.CompareAndSwap(nil, &err)
+}
+
+// replay applies the records read back from the write-ahead log without logging them again.
+// An entry whose TTL elapsed since it was logged is removed. The caller must hold the write lock.
+func (c *core[k, v]) replay(records []walRecord[k, v]) {
+	c.replaying = true
+	defer func() {
+		c.replaying = false
+	}()
+
+	now := c.clock()
+	for _, rec := range records {
+		switch {
+		case rec.deleted:
+			c.remove(rec.key)
+		case rec.deadline == 0:
+			c.set(rec.key, rec.value, noTTL)
+		default:
+			if ttl := time.Unix(0, rec.deadline).Sub(now); ttl > 0 {
+				c.set(rec.key, rec.value, ttl)
+			} else {
+				c.remove(rec.key)
+			}
+		}
+	}
+}
+
+// readWAL decodes the records of a write-ahead log. A truncated last record, left by a crash while it was written,
+// is reported with io.ErrUnexpectedEOF along with the records before it.
+func readWAL[k comparable, v any](data []byte, keys Codec[k], values Codec[v]) ([]walRecord[k, v], error) {
+	var records []walRecord[k, v]
+	field := func() ([]byte, bool) {
+		length, size := binary.Uvarint(data)
+		if size <= 0 || length > uint64(len(data)-size) {
+			return nil, false
+		}
+		f := data[size : size+int(length)]
+		data = data[size+int(length):]
+		return f, true
+	}
+
+	for len(data) > 0 {
+		kind := data[0]
+		data = data[1:]
+		if kind != walSet && kind != walDelete {
+			return records, errCorrupt
+		}
+
+		var rec walRecord[k, v]
+		f, ok := field()
+		if !ok {
+			return records, fmt.Errorf("safemap: write-ahead log truncated after %d records: %w", len(records), io.ErrUnexpectedEOF)
+		}
+		var err error
+		if rec.key, err = keys.Decode(f); err != nil {
+			return records, err
+		}
+
+		if kind == walDelete {
+			rec.deleted = true
+		} else {
+			if f, ok = field(); !ok {
+				return records, fmt.Errorf("safemap: write-ahead log truncated after %d records: %w", len(records), io.ErrUnexpectedEOF)
+			}
+			if rec.value, err = values.Decode(f); err != nil {
+				return records, err
+			}
+			deadline, size := binary.Varint(data)
+			if size <= 0 {
+				return records, fmt.Errorf("safemap: write-ahead log truncated after %d records: %w", len(records), io.ErrUnexpectedEOF)
+			}
+			rec.deadline = deadline
+			data = data[size:]
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// ReplayWAL restores the entries from a write-ahead log written with WithWAL, typically when the process starts.
+// The records are applied in a single operation and are not logged again, so the log can be replayed from
+// the file it keeps being appended to. If the last record was truncated by a crash, the records before it
+// are applied and an error satisfying errors.Is(err, io.ErrUnexpectedEOF) is returned: the log should then be
+// rewritten, for example by saving the map and starting a new log, since records appended after it can't be read.
+// example
+//
+//	f, err := os.OpenFile("state.wal", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	state := NewSafeMap(WithWAL[string, int](f))
+//	if err := state.ReplayWAL(f); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *SafeMap[k, v]) ReplayWAL(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	keys, values := s.load().codecs()
+	records, err := readWAL(data, keys, values)
+	s.send(operation[k, v]{op: "replay", replay: records})
+	return err
+}
+
+// WALError returns the error that stopped the write-ahead log of WithWAL, or nil while it is written.
+// Once writing or encoding a record failed, no further mutation is logged, so a non-nil error means the log
+// no longer covers the entries: it should be checked, for example periodically or before acknowledging writes.
+func (s *SafeMap[k, v]) WALError() error {
+	if err := s.load().walErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
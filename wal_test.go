@@ -0,0 +1,188 @@
+package safemap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWithWAL(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.wal")
+			open := func() *os.File {
+				f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+				assert.NoError(t, err)
+				return f
+			}
+
+			f := open()
+			m := NewSafeMap(append(opts, WithWAL[string, int](f))...)
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Update("a", func(old int, exists bool) (int, bool) { return old + 10, true })
+			m.Delete("b")
+			m.Delete("missing")
+			m.SetMany(map[string]int{"c": 3, "d": 4})
+			assert.NoError(t, m.WALError())
+			assert.NoError(t, m.Close())
+			assert.NoError(t, f.Close())
+
+			// replaying from the file the log keeps being appended to doesn't log the records again
+			f = open()
+			defer f.Close()
+			info, err := f.Stat()
+			assert.NoError(t, err)
+			restored := NewSafeMap(append(opts, WithWAL[string, int](f))...)
+			defer restored.Close()
+			assert.NoError(t, restored.ReplayWAL(f))
+			assert.Equal(t, map[string]int{"a": 11, "c": 3, "d": 4}, restored.GetMap())
+
+			restored.Set("e", 5)
+			after, err := f.Stat()
+			assert.NoError(t, err)
+			assert.Greater(t, after.Size(), info.Size())
+			replayed := NewSafeMap[string, int]()
+			defer replayed.Close()
+			_, err = f.Seek(0, io.SeekStart)
+			assert.NoError(t, err)
+			assert.NoError(t, replayed.ReplayWAL(f))
+			assert.Equal(t, map[string]int{"a": 11, "c": 3, "d": 4, "e": 5}, replayed.GetMap())
+		})
+	}
+}
+
+func TestWithWAL_TTL(t *testing.T) {
+	clock := newFakeClock()
+	var wal bytes.Buffer
+	m := NewSafeMap(withClock[string, int](clock.Now), WithWAL[string, int](&wal))
+	defer m.Close()
+	m.SetWithTTL("a", 1, time.Minute)
+	m.SetWithTTL("b", 2, time.Hour)
+	m.Set("c", 3)
+	m.SetWithTTL("e", 5, time.Minute)
+	m.SetWithTTL("f", 6, time.Minute)
+	// changing the TTL with Expire is logged with the new deadline
+	assert.True(t, m.Expire("e", 3*time.Hour))
+	assert.True(t, m.Expire("f", 0))
+	clock.Advance(2 * time.Minute)
+
+	// the expiration is logged as a removal, and an entry keeps the deadline of its TTL
+	m.Set("d", 4)
+	restored := NewSafeMap(withClock[string, int](clock.Now))
+	defer restored.Close()
+	assert.NoError(t, restored.ReplayWAL(bytes.NewReader(wal.Bytes())))
+	assert.Equal(t, map[string]int{"b": 2, "c": 3, "d": 4, "e": 5, "f": 6}, restored.GetMap())
+	ttl, _ := restored.GetTTL("b")
+	assert.Equal(t, 58*time.Minute, ttl)
+	ttl, _ = restored.GetTTL("e")
+	assert.Equal(t, 178*time.Minute, ttl)
+	ttl, _ = restored.GetTTL("f")
+	assert.Zero(t, ttl)
+
+	// an entry whose TTL elapsed since it was logged is not restored
+	clock.Advance(time.Hour)
+	late := NewSafeMap(withClock[string, int](clock.Now))
+	defer late.Close()
+	assert.NoError(t, late.ReplayWAL(bytes.NewReader(wal.Bytes())))
+	assert.Equal(t, map[string]int{"c": 3, "d": 4, "e": 5, "f": 6}, late.GetMap())
+}
+
+func TestSafeMap_ReplayWAL(t *testing.T) {
+	var wal bytes.Buffer
+	m := NewSafeMap(WithWAL[string, int](&wal))
+	defer m.Close()
+	m.Set("a", 1)
+	m.Set("b", 2)
+
+	// a truncated last record is reported, the records before it are applied
+	restored := NewSafeMap[string, int]()
+	defer restored.Close()
+	err := restored.ReplayWAL(bytes.NewReader(wal.Bytes()[:wal.Len()-2]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, map[string]int{"a": 1}, restored.GetMap())
+
+	assert.ErrorIs(t, restored.ReplayWAL(bytes.NewReader([]byte{9})), errCorrupt)
+}
+
+func TestSafeMap_WALError(t *testing.T) {
+	m := NewSafeMap(WithWAL[string, int](failingWriter{}))
+	defer m.Close()
+	assert.NoError(t, m.WALError())
+
+	m.Set("a", 1)
+	assert.EqualError(t, m.WALError(), "safemap: write-ahead log: disk full")
+	m.Set("b", 2)
+	assert.Equal(t, 2, m.Get("b"), "the map keeps working")
+
+	type value struct{}
+	unencodable := NewSafeMap(WithWAL[string, value](io.Discard))
+	defer unencodable.Close()
+	unencodable.Set("a", value{})
+	assert.ErrorContains(t, unencodable.WALError(), "no binary codec")
+}
+
+func TestShardedSafeMap_WAL(t *testing.T) {
+	// the shards share a log that isn't safe for concurrent use, their writes are serialized
+	var wal bytes.Buffer
+	m := NewShardedSafeMap(8, WithWAL[int, int](&wal))
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w * 100; i < (w+1)*100; i++ {
+				m.Set(i, i)
+				if i%10 == 0 {
+					m.Delete(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// a TTL changed by Expire is logged to the shared log as well
+	m.shard(1).SetWithTTL(1, 1, time.Minute)
+	assert.True(t, m.shard(1).Expire(1, time.Hour))
+	assert.True(t, m.shard(2).Expire(2, time.Hour))
+	assert.NoError(t, m.WALError())
+	want := m.GetMap()
+	assert.Len(t, want, 720)
+	assert.NoError(t, m.Close())
+
+	restored := NewShardedSafeMap[int, int](4)
+	defer restored.Close()
+	assert.NoError(t, restored.ReplayWAL(bytes.NewReader(wal.Bytes())))
+	assert.Equal(t, want, restored.GetMap())
+	for _, key := range []int{1, 2} {
+		ttl, ok := restored.shard(key).GetTTL(key)
+		assert.True(t, ok)
+		assert.Greater(t, ttl, time.Minute)
+	}
+
+	single := NewSafeMap[int, int]()
+	defer single.Close()
+	assert.NoError(t, single.ReplayWAL(bytes.NewReader(wal.Bytes())))
+	assert.Equal(t, want, single.GetMap())
+
+	failing := NewShardedSafeMap(2, WithWAL[int, int](failingWriter{}))
+	defer failing.Close()
+	failing.Set(1, 1)
+	assert.EqualError(t, failing.WALError(), "safemap: write-ahead log: disk full")
+}
@@ -0,0 +1,248 @@
+package safemap
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// EventType is the kind of change reported by Watch.
+type EventType int
+
+const (
+	// EventSet reports a value written to the key.
+	EventSet EventType = iota + 1
+	// EventDelete reports the key removed, explicitly or by eviction.
+	EventDelete
+	// EventExpire reports the key removed because its TTL elapsed.
+	EventExpire
+)
+
+type (
+	// Event is a change of a watched key, see Watch. Value is the value written by an EventSet
+	// and the value removed by an EventDelete or EventExpire.
+	Event[k comparable, v any] struct {
+		Type  EventType
+		Key   k
+		Value v
+	}
+
+	// watcher forwards the changes of a key to the channel returned by Watch.
+	watcher[k comparable, v any] struct {
+		// queue holds the changes not delivered yet, so the worker never waits for the receiver.
+		queue notifier[k, v]
+
+		// stop is closed by the cancel function returned by Watch.
+		stop     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// event returns the change as reported to watchers and subscribers.
+func (n notification[k, v]) event() Event[k, v] {
+	event := Event[k, v]{Type: EventSet, Key: n.key, Value: n.value}
+	switch n.reason {
+	case 0:
+	case EvictionExpired:
+		event.Type = EventExpire
+	default:
+		event.Type = EventDelete
+	}
+	return event
+}
+
+// watch reports a change to the watchers of its key and to the subscribers. The caller must hold the write lock.
+// A subscriber whose buffer is full is dropped, its channel is closed.
+func (c *core[k, v]) watch(change notification[k, v]) {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	for _, w := range c.watchers[change.key] {
+		w.queue.push(change)
+	}
+
+	if len(c.subscribers) == 0 {
+		return
+	}
+	event := change.event()
+	for sub := range c.subscribers {
+		select {
+		case sub <- event:
+		default:
+			c.unsubscribe(sub)
+		}
+	}
+}
+
+// unsubscribe closes the channel of a subscriber, the caller must hold watchMu.
+func (c *core[k, v]) unsubscribe(sub chan Event[k, v]) {
+	if _, ok := c.subscribers[sub]; ok {
+		delete(c.subscribers, sub)
+		close(sub)
+		c.watched.Add(-1)
+	}
+}
+
+// forward delivers the queued changes to events until the watch is cancelled or the SafeMap closed,
+// then closes events.
+func (w *watcher[k, v]) forward(events chan<- Event[k, v], done <-chan struct{}) {
+	defer close(events)
+	for {
+		select {
+		case <-w.queue.wake:
+		case <-w.stop:
+			return
+		case <-done:
+			return
+		}
+
+		for _, n := range w.queue.take() {
+			select {
+			case events <- n.event():
+			case <-w.stop:
+				return
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// Watch delivers the changes of key on the returned channel: values written, and the key removed or expired,
+// in the order they happen. Changes are queued for the receiver, so a slow receiver never blocks the SafeMap.
+// The channel is closed once cancel is called or the SafeMap is closed. cancel may be called several times.
+// example
+//
+//	events, cancel := config.Watch("feature-flags")
+//	defer cancel()
+//	for event := range events {
+//		if event.Type == EventSet {
+//			reload(event.Value)
+//		}
+//	}
+func (s *SafeMap[k, v]) Watch(key k) (<-chan Event[k, v], func()) {
+	c := s.load()
+	w := &watcher[k, v]{
+		queue: notifier[k, v]{wake: make(chan struct{}, 1)},
+		stop:  make(chan struct{}),
+	}
+	events := make(chan Event[k, v])
+
+	c.watchMu.Lock()
+	if c.watchers == nil {
+		c.watchers = make(map[k][]*watcher[k, v])
+	}
+	c.watchers[key] = append(c.watchers[key], w)
+	c.watched.Add(1)
+	c.watchMu.Unlock()
+
+	go w.forward(events, c.done)
+
+	cancel := func() {
+		w.stopOnce.Do(func() {
+			close(w.stop)
+
+			c.watchMu.Lock()
+			defer c.watchMu.Unlock()
+			c.watched.Add(-1)
+			watchers := slices.DeleteFunc(c.watchers[key], func(other *watcher[k, v]) bool { return other == w })
+			if len(watchers) == 0 {
+				delete(c.watchers, key)
+			} else {
+				c.watchers[key] = watchers
+			}
+		})
+	}
+	return events, cancel
+}
+
+// Subscribe delivers every change of the SafeMap on the returned channel: values written, and keys deleted or expired,
+// in the order they happen. It is a change feed, for example to replicate the entries into another process.
+//
+// The channel is buffered, with room for WithSubscriptionBuffer events. The SafeMap never waits for a subscriber:
+// a subscriber falling behind by a full buffer is dropped and its channel is closed after the events it holds,
+// it then missed the following changes and must start over, such as with GetMap and a new subscription.
+// The channel is also closed once cancel is called or the SafeMap is closed. cancel may be called several times.
+// example
+//
+//	events, cancel := m.Subscribe()
+//	defer cancel()
+//	for event := range events {
+//		replicate(event)
+//	}
+func (s *SafeMap[k, v]) Subscribe() (<-chan Event[k, v], func()) {
+	c := s.load()
+	buffer := c.cfg.subscriptionBuffer
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+	sub := make(chan Event[k, v], buffer)
+
+	c.watchMu.Lock()
+	select {
+	case <-c.done:
+		close(sub)
+	default:
+		if c.subscribers == nil {
+			c.subscribers = make(map[chan Event[k, v]]struct{})
+		}
+		c.subscribers[sub] = struct{}{}
+		c.watched.Add(1)
+	}
+	c.watchMu.Unlock()
+
+	cancel := func() {
+		c.watchMu.Lock()
+		defer c.watchMu.Unlock()
+		c.unsubscribe(sub)
+	}
+	return sub, cancel
+}
+
+// Events returns the change feed of Subscribe as an iterator, it subscribes when the iteration starts
+// and stops once ctx is done, the loop breaks, the subscriber falls behind or the SafeMap is closed.
+// example
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	for event := range m.Events(ctx) {
+//		replicate(event)
+//	}
+func (s *SafeMap[k, v]) Events(ctx context.Context) iter.Seq[Event[k, v]] {
+	return func(yield func(Event[k, v]) bool) {
+		events, cancel := s.Subscribe()
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok || ctx.Err() != nil || !yield(event) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// closeSubscribers closes the channel of every subscriber of a closed SafeMap.
+func (c *core[k, v]) closeSubscribers() {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	for sub := range c.subscribers {
+		c.unsubscribe(sub)
+	}
+}
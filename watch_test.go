@@ -0,0 +1,174 @@
+package safemap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeMap_Watch(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now))...)
+
+			events, cancel := m.Watch("a")
+			other, cancelOther := m.Watch("a")
+
+			// the receiver doesn't need to keep up with the changes
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Update("a", func(old int, exists bool) (int, bool) { return old + 1, true })
+			m.Delete("a")
+			m.SetWithTTL("a", 3, time.Minute)
+			clock.Advance(time.Minute)
+			m.Delete("z")
+
+			expected := []Event[string, int]{
+				{Type: EventSet, Key: "a", Value: 1},
+				{Type: EventSet, Key: "a", Value: 2},
+				{Type: EventDelete, Key: "a", Value: 2},
+				{Type: EventSet, Key: "a", Value: 3},
+				{Type: EventExpire, Key: "a", Value: 3},
+			}
+			for _, event := range expected {
+				assert.Equal(t, event, <-events)
+			}
+
+			// cancelling closes the channel, the other watchers keep receiving the changes
+			cancel()
+			cancel()
+			_, ok := <-events
+			assert.False(t, ok)
+			m.Set("a", 4)
+			for _, event := range expected {
+				assert.Equal(t, event, <-other)
+			}
+			assert.Equal(t, Event[string, int]{Type: EventSet, Key: "a", Value: 4}, <-other)
+
+			assert.NoError(t, m.Close())
+			_, ok = <-other
+			assert.False(t, ok)
+			cancelOther()
+			assert.Empty(t, m.watchers)
+		})
+	}
+}
+
+func TestSafeMap_Subscribe(t *testing.T) {
+	for name, opts := range map[string][]Option[string, int]{
+		"worker": nil,
+		"mutex":  {WithMutex[string, int]()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := NewSafeMap(append(opts, withClock[string, int](clock.Now))...)
+
+			events, cancel := m.Subscribe()
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Delete("a")
+			m.SetWithTTL("c", 3, time.Minute)
+			clock.Advance(time.Minute)
+			m.Delete("z")
+
+			expected := []Event[string, int]{
+				{Type: EventSet, Key: "a", Value: 1},
+				{Type: EventSet, Key: "b", Value: 2},
+				{Type: EventDelete, Key: "a", Value: 1},
+				{Type: EventSet, Key: "c", Value: 3},
+				{Type: EventExpire, Key: "c", Value: 3},
+			}
+			for _, event := range expected {
+				assert.Equal(t, event, <-events)
+			}
+
+			cancel()
+			cancel()
+			_, ok := <-events
+			assert.False(t, ok)
+			assert.Zero(t, m.watched.Load())
+
+			// closing the SafeMap closes the channels of the subscribers
+			events, _ = m.Subscribe()
+			assert.NoError(t, m.Close())
+			_, ok = <-events
+			assert.False(t, ok)
+			events, _ = m.Subscribe()
+			_, ok = <-events
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSafeMap_Subscribe_Overflow(t *testing.T) {
+	m := NewSafeMap(WithSubscriptionBuffer[string, int](2))
+	defer m.Close()
+
+	slow, cancel := m.Subscribe()
+	defer cancel()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	fast, _ := m.Subscribe()
+	m.Set("c", 3)
+
+	// the slow subscriber is dropped once its buffer is full, after the events it holds
+	assert.Equal(t, Event[string, int]{Type: EventSet, Key: "a", Value: 1}, <-slow)
+	assert.Equal(t, Event[string, int]{Type: EventSet, Key: "b", Value: 2}, <-slow)
+	_, ok := <-slow
+	assert.False(t, ok)
+	assert.Equal(t, Event[string, int]{Type: EventSet, Key: "c", Value: 3}, <-fast)
+}
+
+func TestSafeMap_Events(t *testing.T) {
+	m := NewSafeMap[string, int]()
+	defer m.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	received := make(chan []Event[string, int])
+	go func() {
+		var events []Event[string, int]
+		close(started)
+		for event := range m.Events(ctx) {
+			events = append(events, event)
+			if len(events) == 2 {
+				cancel()
+			}
+		}
+		received <- events
+	}()
+
+	<-started
+	assert.Eventually(t, func() bool { return m.watched.Load() == 1 }, time.Second, time.Millisecond)
+	m.Set("a", 1)
+	m.Delete("a")
+	assert.Equal(t, []Event[string, int]{
+		{Type: EventSet, Key: "a", Value: 1},
+		{Type: EventDelete, Key: "a", Value: 1},
+	}, <-received)
+	assert.Zero(t, m.watched.Load())
+
+	// breaking the loop cancels the subscription
+	go func() {
+		assert.Eventually(t, func() bool { return m.watched.Load() == 1 }, time.Second, time.Millisecond)
+		m.Set("b", 2)
+	}()
+	for event := range m.Events(context.Background()) {
+		assert.Equal(t, Event[string, int]{Type: EventSet, Key: "b", Value: 2}, event)
+		break
+	}
+	assert.Zero(t, m.watched.Load())
+}
+
+func TestEventType(t *testing.T) {
+	assert.Equal(t, "set", EventSet.String())
+	assert.Equal(t, "delete", EventDelete.String())
+	assert.Equal(t, "expire", EventExpire.String())
+	assert.Equal(t, "unknown", EventType(0).String())
+}